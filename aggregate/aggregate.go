@@ -0,0 +1,132 @@
+// Package aggregate is a Go port of the Pyth oracle price aggregation.
+//
+// It mirrors upd_aggregate and price_model_core of the on-chain program.
+package aggregate
+
+import (
+	"math"
+	"sort"
+
+	"go.blockdaemon.com/pyth"
+)
+
+// MaxSendLatency is the default number of slots a quote remains eligible for aggregation.
+const MaxSendLatency = 25
+
+// Quote is a component price published by a single publisher.
+type Quote struct {
+	Price   int64  `json:"price"`
+	Conf    uint64 `json:"conf"`
+	Status  uint32 `json:"status"`
+	PubSlot uint64 `json:"pub_slot"`
+}
+
+// Result is an aggregate price.
+type Result struct {
+	Price     int64  `json:"price"`
+	Conf      uint64 `json:"conf"`
+	Status    uint32 `json:"status"`
+	NumQuotes int    `json:"num_quotes"`
+}
+
+// Params configures the aggregation.
+type Params struct {
+	MaxLatency    uint64 // max age of quotes in slots, MaxSendLatency if zero
+	MinPublishers int    // min number of valid quotes
+}
+
+// AccountParams returns the aggregation parameters configured in a price account.
+//
+// The on-chain program stores min_pub and max_latency in the first and third byte
+// of the field pyth.PriceAccount decodes as Drv2.
+func AccountParams(acc *pyth.PriceAccount) Params {
+	return Params{
+		MaxLatency:    uint64(uint8(acc.Drv2 >> 16)),
+		MinPublishers: int(uint8(acc.Drv2)),
+	}
+}
+
+// Compute runs the aggregation over the given quotes at the given slot.
+//
+// If there are not enough valid quotes, returns a result with unknown status.
+func Compute(quotes []Quote, slot uint64, params Params) Result {
+	maxLatency := params.MaxLatency
+	if maxLatency == 0 {
+		maxLatency = MaxSendLatency
+	}
+
+	// Identify valid quotes.
+	prices := make([]int64, 0, 3*len(quotes))
+	for _, q := range quotes {
+		if q.Status != pyth.PriceStatusTrading {
+			continue
+		}
+		conf := int64(q.Conf)
+		if conf <= 0 || q.Price < math.MinInt64+conf || q.Price > math.MaxInt64-conf {
+			continue
+		}
+		if q.PubSlot > slot || slot-q.PubSlot > maxLatency {
+			continue
+		}
+		prices = append(prices, q.Price-conf, q.Price, q.Price+conf)
+	}
+
+	// Too few valid quotes.
+	numQuotes := len(prices) / 3
+	if numQuotes == 0 || numQuotes < params.MinPublishers {
+		return Result{Status: pyth.PriceStatusUnknown, NumQuotes: numQuotes}
+	}
+
+	// Evaluate the model to get the p25/p50/p75 prices.
+	p25, p50, p75 := priceModel(prices)
+
+	// Use the larger of the left and right confidences.
+	conf := p50 - p25
+	if right := p75 - p50; right > conf {
+		conf = right
+	}
+	if conf <= 0 {
+		return Result{Status: pyth.PriceStatusUnknown, NumQuotes: numQuotes}
+	}
+
+	return Result{
+		Price:     p50,
+		Conf:      uint64(conf),
+		Status:    pyth.PriceStatusTrading,
+		NumQuotes: numQuotes,
+	}
+}
+
+// priceModel returns the 25th, 50th, and 75th percentile of the given prices.
+//
+// The prices slice gets sorted in-place.
+func priceModel(prices []int64) (p25, p50, p75 int64) {
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	cnt := len(prices)
+
+	p25Idx := cnt >> 2
+	if cnt&2 != 0 {
+		p25 = prices[p25Idx]
+	} else {
+		p25 = avg(prices[p25Idx-1], prices[p25Idx])
+	}
+
+	if cnt&1 != 0 {
+		p50 = prices[cnt>>1]
+	} else {
+		p50 = avg(prices[(cnt>>1)-1], prices[cnt>>1])
+	}
+
+	p75Idx := cnt - 1 - p25Idx
+	if cnt&2 != 0 {
+		p75 = prices[p75Idx]
+	} else {
+		p75 = avg(prices[p75Idx], prices[p75Idx+1])
+	}
+	return
+}
+
+// avg returns floor((x+y)/2) without overflowing.
+func avg(x, y int64) int64 {
+	return (x >> 1) + (y >> 1) + (x & y & 1)
+}
@@ -0,0 +1,90 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+// fixture is a snapshot of the component quotes of a price account
+// along with the aggregate price computed from them.
+//
+// On-chain snapshots in testdata are recorded with testdata/record.go.
+// Hand-written cases for edge cases live in testdata/synthetic.
+type fixture struct {
+	Source        string  `json:"source"` // where the snapshot came from
+	Slot          uint64  `json:"slot"`
+	MinPublishers int     `json:"min_publishers"`
+	MaxLatency    uint64  `json:"max_latency"`
+	Components    []Quote `json:"components"`
+	Aggregate     Result  `json:"aggregate"`
+}
+
+func TestCompute_Recorded(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.json")
+	require.NoError(t, err)
+	if len(paths) == 0 {
+		t.Skip("no recorded snapshots in testdata")
+	}
+	testFixtures(t, paths)
+}
+
+func TestCompute_Synthetic(t *testing.T) {
+	paths, err := filepath.Glob("testdata/synthetic/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	testFixtures(t, paths)
+}
+
+func testFixtures(t *testing.T, paths []string) {
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var fix fixture
+			require.NoError(t, json.Unmarshal(data, &fix))
+			require.NotEmpty(t, fix.Source, "fixture must record its source")
+
+			params := Params{MinPublishers: fix.MinPublishers, MaxLatency: fix.MaxLatency}
+			result := Compute(fix.Components, fix.Slot, params)
+			assert.Equal(t, fix.Aggregate, result)
+		})
+	}
+}
+
+func TestAccountParams(t *testing.T) {
+	// min_pub 3, message_sent 1, max_latency 40
+	acc := &pyth.PriceAccount{Drv2: 3 | 1<<8 | 40<<16}
+	assert.Equal(t, Params{MinPublishers: 3, MaxLatency: 40}, AccountParams(acc))
+
+	quotes := []Quote{
+		{Price: 100, Conf: 1, Status: pyth.PriceStatusTrading, PubSlot: 990},
+		{Price: 101, Conf: 1, Status: pyth.PriceStatusTrading, PubSlot: 990},
+	}
+	assert.Equal(t, uint32(pyth.PriceStatusUnknown), Compute(quotes, 1000, AccountParams(acc)).Status)
+	acc.Drv2 = 2
+	assert.Equal(t, uint32(pyth.PriceStatusTrading), Compute(quotes, 1000, AccountParams(acc)).Status)
+}
+
+func TestAvg(t *testing.T) {
+	cases := []struct {
+		x, y, avg int64
+	}{
+		{1, 2, 1},
+		{3, 3, 3},
+		{-3, -4, -4},
+		{-1, 2, 0},
+		{9223372036854775807, 9223372036854775807, 9223372036854775807},
+		{-9223372036854775808, -9223372036854775808, -9223372036854775808},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.avg, avg(tc.x, tc.y), "avg(%d, %d)", tc.x, tc.y)
+	}
+}
@@ -0,0 +1,87 @@
+//go:build ignore
+// +build ignore
+
+// Record writes a price account snapshot as an aggregation test fixture.
+//
+// The on-chain program copies each component's latest quote into its "agg" field
+// when aggregating, so a single snapshot holds both the inputs and the result.
+//
+//	go run ./aggregate/testdata/record.go -network mainnet -price <account> > aggregate/testdata/<name>.json
+//
+// Snapshots go directly into testdata, hand-written cases into testdata/synthetic.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/aggregate"
+)
+
+func main() {
+	network := flag.String("network", "mainnet", "Solana network (devnet, testnet, mainnet)")
+	rpcURL := flag.String("rpc", "https://api.mainnet-beta.solana.com", "RPC URL")
+	priceFlag := flag.String("price", "", "Price account")
+	flag.Parse()
+
+	envs := map[string]pyth.Env{"devnet": pyth.Devnet, "testnet": pyth.Testnet, "mainnet": pyth.Mainnet}
+	env, ok := envs[*network]
+	if !ok {
+		log.Fatalf("unsupported network: %s", *network)
+	}
+	priceKey, err := solana.PublicKeyFromBase58(*priceFlag)
+	if err != nil {
+		log.Fatalf("invalid price account: %s", err)
+	}
+
+	client := pyth.NewClient(env, *rpcURL, "")
+	ctx := context.Background()
+	entry, err := client.GetPriceAccount(ctx, priceKey, rpc.CommitmentFinalized)
+	if err != nil {
+		log.Fatalf("failed to get price account: %s", err)
+	}
+
+	var fixture struct {
+		Source        string            `json:"source"`
+		Slot          uint64            `json:"slot"`
+		MinPublishers int               `json:"min_publishers"`
+		MaxLatency    uint64            `json:"max_latency"`
+		Components    []aggregate.Quote `json:"components"`
+		Aggregate     aggregate.Result  `json:"aggregate"`
+	}
+	fixture.Source = fmt.Sprintf("%s price account %s, aggregated at slot %d", *network, priceKey, entry.Agg.PubSlot)
+	fixture.Slot = entry.Agg.PubSlot
+	params := aggregate.AccountParams(entry.PriceAccount)
+	fixture.MinPublishers = params.MinPublishers
+	fixture.MaxLatency = params.MaxLatency
+	for _, comp := range entry.Components {
+		if comp.Publisher.IsZero() {
+			continue
+		}
+		fixture.Components = append(fixture.Components, aggregate.Quote{
+			Price:   comp.Agg.Price,
+			Conf:    comp.Agg.Conf,
+			Status:  comp.Agg.Status,
+			PubSlot: comp.Agg.PubSlot,
+		})
+	}
+	fixture.Aggregate = aggregate.Result{
+		Price:     entry.Agg.Price,
+		Conf:      entry.Agg.Conf,
+		Status:    entry.Agg.Status,
+		NumQuotes: int(entry.NumQt),
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&fixture); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,203 @@
+// Package calendar describes market trading hours.
+//
+// A config file defines named calendars with weekly sessions, holidays and a time zone,
+// and assigns them to products by symbol or asset type.
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Modes of handling trading updates outside of a session.
+const (
+	ModeOverride = "override" // replace the status
+	ModeReject   = "reject"   // reject the update
+)
+
+// Config assigns calendars to products.
+type Config struct {
+	Calendars  map[string]*Calendar `json:"calendars"`
+	Symbols    map[string]string    `json:"symbols"`     // product symbol => calendar name
+	AssetTypes map[string]string    `json:"asset_types"` // product asset type => calendar name
+}
+
+// Calendar is a weekly trading schedule in a time zone.
+type Calendar struct {
+	TimeZone      string    `json:"time_zone"`
+	Sessions      []Session `json:"sessions"`
+	Holidays      []string  `json:"holidays"`       // dates in YYYY-MM-DD format
+	Status        string    `json:"status"`         // status to use outside a session, e.g. "halted"
+	Mode          string    `json:"mode"`           // ModeOverride or ModeReject
+	ClosingStatus bool      `json:"closing_status"` // publish Status once when a session closes
+
+	location *time.Location
+	holidays map[string]bool
+}
+
+// Session is a daily trading window.
+//
+// If Close is not after Open, the session spans midnight and ends on the following day.
+type Session struct {
+	Days  []string `json:"days"`  // weekdays the session opens on, e.g. "mon"
+	Open  string   `json:"open"`  // local time in HH:MM format
+	Close string   `json:"close"` // local time in HH:MM format
+
+	days  [7]bool
+	open  time.Duration
+	close time.Duration
+}
+
+// Load reads and validates a config file.
+func Load(path string) (*Config, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(buf, &config); err != nil {
+		return nil, err
+	}
+	if err := config.init(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *Config) init() error {
+	for name, cal := range c.Calendars {
+		if err := cal.init(); err != nil {
+			return fmt.Errorf("calendar %s: %w", name, err)
+		}
+	}
+	for symbol, name := range c.Symbols {
+		if _, ok := c.Calendars[name]; !ok {
+			return fmt.Errorf("symbol %s: unknown calendar %s", symbol, name)
+		}
+	}
+	for assetType, name := range c.AssetTypes {
+		if _, ok := c.Calendars[name]; !ok {
+			return fmt.Errorf("asset type %s: unknown calendar %s", assetType, name)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the calendar of a product given its attributes, or nil if it trades around the clock.
+//
+// Symbol assignments take precedence over asset types.
+func (c *Config) Lookup(attrs map[string]string) *Calendar {
+	if name, ok := c.Symbols[attrs["symbol"]]; ok {
+		return c.Calendars[name]
+	}
+	if name, ok := c.AssetTypes[attrs["asset_type"]]; ok {
+		return c.Calendars[name]
+	}
+	return nil
+}
+
+func (c *Calendar) init() error {
+	var err error
+	if c.location, err = time.LoadLocation(c.TimeZone); err != nil {
+		return err
+	}
+	switch c.Mode {
+	case "":
+		c.Mode = ModeOverride
+	case ModeOverride, ModeReject:
+	default:
+		return fmt.Errorf("invalid mode: %s", c.Mode)
+	}
+	switch c.Status {
+	case "":
+		c.Status = "unknown"
+	case "unknown", "halted", "auction":
+	default:
+		return fmt.Errorf("invalid status: %s", c.Status)
+	}
+	c.holidays = make(map[string]bool, len(c.Holidays))
+	for _, day := range c.Holidays {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			return fmt.Errorf("invalid holiday: %w", err)
+		}
+		c.holidays[day] = true
+	}
+	for i := range c.Sessions {
+		if err := c.Sessions[i].init(); err != nil {
+			return fmt.Errorf("session %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func (s *Session) init() error {
+	for _, day := range s.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("invalid day: %s", day)
+		}
+		s.days[weekday] = true
+	}
+	var err error
+	if s.open, err = parseClock(s.Open); err != nil {
+		return fmt.Errorf("invalid open: %w", err)
+	}
+	if s.close, err = parseClock(s.Close); err != nil {
+		return fmt.Errorf("invalid close: %w", err)
+	}
+	return nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// IsOpen returns whether any session is open at the given time.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	t = t.In(c.location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.location)
+	// Use wall clock time, as days with DST transitions are not 24 hours long.
+	sinceMidnight := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	yesterday := midnight.AddDate(0, 0, -1)
+	for i := range c.Sessions {
+		s := &c.Sessions[i]
+		if s.close > s.open {
+			if s.opensOn(c, midnight) && sinceMidnight >= s.open && sinceMidnight < s.close {
+				return true
+			}
+			continue
+		}
+		// Session spans midnight.
+		if s.opensOn(c, midnight) && sinceMidnight >= s.open {
+			return true
+		}
+		if s.opensOn(c, yesterday) && sinceMidnight < s.close {
+			return true
+		}
+	}
+	return false
+}
+
+// opensOn returns whether the session opens on the given local date.
+func (s *Session) opensOn(c *Calendar, date time.Time) bool {
+	return s.days[date.Weekday()] && !c.holidays[date.Format("2006-01-02")]
+}
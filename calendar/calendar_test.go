@@ -0,0 +1,75 @@
+package calendar
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `{
+  "calendars": {
+    "us_equity": {
+      "time_zone": "America/New_York",
+      "sessions": [{"days": ["mon", "tue", "wed", "thu", "fri"], "open": "09:30", "close": "16:00"}],
+      "holidays": ["2026-11-26"],
+      "status": "halted",
+      "closing_status": true
+    },
+    "fx": {
+      "time_zone": "America/New_York",
+      "sessions": [{"days": ["sun", "mon", "tue", "wed", "thu"], "open": "17:00", "close": "17:00"}],
+      "mode": "reject"
+    }
+  },
+  "symbols": {"Equity.US.SPY/USD": "us_equity"},
+  "asset_types": {"FX": "fx"}
+}`
+
+func loadTestConfig(t *testing.T) *Config {
+	var config Config
+	require.NoError(t, json.Unmarshal([]byte(testConfig), &config))
+	require.NoError(t, config.init())
+	return &config
+}
+
+func TestConfig_Lookup(t *testing.T) {
+	config := loadTestConfig(t)
+	assert.Same(t, config.Calendars["us_equity"], config.Lookup(map[string]string{"symbol": "Equity.US.SPY/USD", "asset_type": "Equity"}))
+	assert.Same(t, config.Calendars["fx"], config.Lookup(map[string]string{"symbol": "FX.EUR/USD", "asset_type": "FX"}))
+	assert.Nil(t, config.Lookup(map[string]string{"symbol": "Crypto.BTC/USD", "asset_type": "Crypto"}))
+
+	assert.Equal(t, ModeOverride, config.Calendars["us_equity"].Mode)
+	assert.Equal(t, "unknown", config.Calendars["fx"].Status)
+}
+
+func TestCalendar_IsOpen(t *testing.T) {
+	config := loadTestConfig(t)
+	cases := []struct {
+		calendar string
+		time     string
+		open     bool
+	}{
+		{"us_equity", "2026-10-19T13:29:59Z", false}, // Monday 09:29:59 EDT
+		{"us_equity", "2026-10-19T13:30:00Z", true},  // Monday 09:30 EDT
+		{"us_equity", "2026-10-19T19:59:59Z", true},  // Monday 15:59:59 EDT
+		{"us_equity", "2026-10-19T20:00:00Z", false}, // Monday 16:00 EDT
+		{"us_equity", "2026-10-18T15:00:00Z", false}, // Sunday
+		{"us_equity", "2026-11-02T14:30:00Z", true},  // Monday 09:30 EST, after DST ends
+		{"us_equity", "2026-11-02T13:30:00Z", false}, // Monday 08:30 EST
+		{"us_equity", "2026-11-26T16:00:00Z", false}, // Thanksgiving
+		{"fx", "2026-10-18T20:59:59Z", false},        // Sunday 16:59:59 EDT
+		{"fx", "2026-10-18T21:00:00Z", true},         // Sunday 17:00 EDT
+		{"fx", "2026-10-21T03:00:00Z", true},         // Tuesday 23:00 EDT
+		{"fx", "2026-10-23T20:59:59Z", true},         // Friday 16:59:59 EDT
+		{"fx", "2026-10-23T21:00:00Z", false},        // Friday 17:00 EDT
+		{"fx", "2026-10-24T12:00:00Z", false},        // Saturday
+	}
+	for _, tc := range cases {
+		ts, err := time.Parse(time.RFC3339, tc.time)
+		require.NoError(t, err)
+		assert.Equal(t, tc.open, config.Calendars[tc.calendar].IsOpen(ts), "%s at %s", tc.calendar, tc.time)
+	}
+}
@@ -18,7 +18,10 @@ var (
 	flagWS     = pflag.String("ws", "", "WebSocket RPC URL")
 
 	FlagSetSigner  = pflag.NewFlagSet("signer", pflag.ExitOnError)
-	flagPrivateKey = pflag.String("private-key-file", "", "Path to private key file")
+	flagPrivateKey = FlagSetSigner.StringArray("private-key-file", nil, "Path to private key file (repeat to load multiple publisher keys)")
+
+	FlagSetHistory = pflag.NewFlagSet("history", pflag.ExitOnError)
+	FlagHistoryDir = FlagSetHistory.String("history-dir", "", "Directory of price history store")
 )
 
 func GetRPCFlag() (*url.URL, error) {
@@ -74,9 +77,9 @@ func GetPythEnv() (pyth.Env, error) {
 	return pythEnv, nil
 }
 
-func GetPrivateKeyPath() string {
+func GetPrivateKeyPaths() []string {
 	v := *flagPrivateKey
-	if v == "" {
+	if len(v) == 0 {
 		cobra.CheckErr("Missing private key flag")
 	}
 	return v
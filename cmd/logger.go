@@ -9,6 +9,10 @@ import (
 var (
 	FlagLogLevel  = LogLevel{zap.InfoLevel}
 	FlagLogFormat = FlagSetCommon.String("log-format", "console", "Log format (console, json)")
+
+	// AtomicLogLevel is the level of the logger returned by GetLogger.
+	// It can be changed at runtime.
+	AtomicLogLevel = zap.NewAtomicLevel()
 )
 
 func init() {
@@ -24,7 +28,8 @@ func GetLogger() *zap.Logger {
 		config.DisableStacktrace = true
 	}
 	config.DisableCaller = true
-	config.Level.SetLevel(FlagLogLevel.Level)
+	AtomicLogLevel.SetLevel(FlagLogLevel.Level)
+	config.Level = AtomicLogLevel
 	logger, err := config.Build()
 	cobra.CheckErr(err)
 	return logger
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// LabeledGatherer adds a constant label to all metrics of the given gatherer.
+func LabeledGatherer(g prometheus.Gatherer, name, value string) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := g.Gather()
+		for _, family := range families {
+			for _, metric := range family.Metric {
+				metric.Label = append(metric.Label, &dto.LabelPair{Name: &name, Value: &value})
+				sort.Slice(metric.Label, func(i, j int) bool {
+					return metric.Label[i].GetName() < metric.Label[j].GetName()
+				})
+			}
+		}
+		return families, err
+	})
+}
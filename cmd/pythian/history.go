@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/spf13/cobra"
+	"go.blockdaemon.com/pythian/cmd"
+	"go.blockdaemon.com/pythian/history"
+)
+
+var historyCmd = cobra.Command{
+	Use:   "history",
+	Short: "Access local price history",
+}
+
+var historyExportCmd = cobra.Command{
+	Use:   "export",
+	Short: "Export price history as CSV or NDJSON",
+	Args:  cobra.NoArgs,
+	Run:   runHistoryExport,
+}
+
+var (
+	historyExportFlags         = historyExportCmd.Flags()
+	historyExportAccountFlag   string
+	historyExportPublisherFlag string
+	historyExportFromSlotFlag  uint64
+	historyExportToSlotFlag    uint64
+	historyExportFromTimeFlag  string
+	historyExportToTimeFlag    string
+	historyExportFormatFlag    string
+	historyExportOutputFlag    string
+)
+
+func init() {
+	rootCmd.AddCommand(&historyCmd)
+	historyCmd.AddCommand(&historyExportCmd)
+	historyExportFlags.AddFlagSet(cmd.FlagSetHistory)
+	historyExportFlags.StringVar(&historyExportAccountFlag, "account", "", "Price account (all if empty)")
+	historyExportFlags.StringVar(&historyExportPublisherFlag, "publisher", "", `Publisher key, or "aggregate" (all if empty)`)
+	historyExportFlags.Uint64Var(&historyExportFromSlotFlag, "from-slot", 0, "First slot to export")
+	historyExportFlags.Uint64Var(&historyExportToSlotFlag, "to-slot", 0, "Last slot to export")
+	historyExportFlags.StringVar(&historyExportFromTimeFlag, "from-time", "", "Start time (RFC 3339)")
+	historyExportFlags.StringVar(&historyExportToTimeFlag, "to-time", "", "End time (RFC 3339)")
+	historyExportFlags.StringVar(&historyExportFormatFlag, "format", "csv", "Output format (csv, ndjson)")
+	historyExportFlags.StringVarP(&historyExportOutputFlag, "output", "o", "-", "Output file")
+}
+
+func runHistoryExport(_ *cobra.Command, _ []string) {
+	if *cmd.FlagHistoryDir == "" {
+		cobra.CheckErr("--history-dir not set")
+	}
+
+	var query history.Query
+	var err error
+	if historyExportAccountFlag != "" {
+		query.Account, err = solana.PublicKeyFromBase58(historyExportAccountFlag)
+		cobra.CheckErr(err)
+	}
+	switch historyExportPublisherFlag {
+	case "":
+	case "aggregate":
+		query.Publisher = &solana.PublicKey{}
+	default:
+		publisher, err := solana.PublicKeyFromBase58(historyExportPublisherFlag)
+		cobra.CheckErr(err)
+		query.Publisher = &publisher
+	}
+	query.FromSlot = historyExportFromSlotFlag
+	query.ToSlot = historyExportToSlotFlag
+	if historyExportFromTimeFlag != "" {
+		query.FromTime, err = time.Parse(time.RFC3339, historyExportFromTimeFlag)
+		cobra.CheckErr(err)
+	}
+	if historyExportToTimeFlag != "" {
+		query.ToTime, err = time.Parse(time.RFC3339, historyExportToTimeFlag)
+		cobra.CheckErr(err)
+	}
+
+	var write func(io.Writer) func(history.Record) bool
+	switch historyExportFormatFlag {
+	case "csv":
+		write = writeHistoryCSV
+	case "ndjson":
+		write = writeHistoryNDJSON
+	default:
+		cobra.CheckErr(fmt.Errorf("unknown format: %s", historyExportFormatFlag))
+	}
+
+	var out io.Writer = os.Stdout
+	if historyExportOutputFlag != "-" {
+		f, err := os.Create(historyExportOutputFlag)
+		cobra.CheckErr(err)
+		defer f.Close()
+		out = f
+	}
+	wr := bufio.NewWriter(out)
+	defer wr.Flush()
+
+	// Never delete anything when only reading.
+	opts := history.DefaultOptions()
+	opts.MaxAge = 0
+	store, err := history.Open(*cmd.FlagHistoryDir, opts)
+	cobra.CheckErr(err)
+	defer store.Close()
+
+	cobra.CheckErr(store.Scan(query, write(wr)))
+}
+
+func writeHistoryCSV(wr io.Writer) func(history.Record) bool {
+	csvWr := csv.NewWriter(wr)
+	_ = csvWr.Write([]string{"account", "publisher", "slot", "time", "price", "conf", "status"})
+	return func(r history.Record) bool {
+		var publisher string
+		if !r.IsAggregate() {
+			publisher = r.Publisher.String()
+		}
+		err := csvWr.Write([]string{
+			r.Account.String(),
+			publisher,
+			strconv.FormatUint(r.Slot, 10),
+			r.Time.Format(time.RFC3339Nano),
+			strconv.FormatInt(r.Price, 10),
+			strconv.FormatUint(r.Conf, 10),
+			strconv.FormatUint(uint64(r.Status), 10),
+		})
+		csvWr.Flush()
+		cobra.CheckErr(err)
+		return true
+	}
+}
+
+func writeHistoryNDJSON(wr io.Writer) func(history.Record) bool {
+	enc := json.NewEncoder(wr)
+	return func(r history.Record) bool {
+		cobra.CheckErr(enc.Encode(r))
+		return true
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	solana_rpc "github.com/gagliardetto/solana-go/rpc"
+	"github.com/spf13/cobra"
+	"go.blockdaemon.com/pythian/cmd"
+	"go.blockdaemon.com/pythian/schedule"
+	"go.uber.org/zap"
+)
+
+var nonceCmd = cobra.Command{
+	Use:   "nonce",
+	Short: "Manage durable nonce accounts",
+}
+
+var nonceCreateCmd = cobra.Command{
+	Use:   "create",
+	Short: "Create a durable nonce account for publishing",
+	Long: "Creates a durable nonce account funded by the first private key.\n" +
+		"Pass the printed address to server --nonce-accounts.",
+	Args: cobra.NoArgs,
+	Run:  runNonceCreate,
+}
+
+var (
+	nonceCreateFlags         = nonceCreateCmd.Flags()
+	nonceCreateAuthorityFlag string
+)
+
+func init() {
+	rootCmd.AddCommand(&nonceCmd)
+	nonceCmd.AddCommand(&nonceCreateCmd)
+	nonceCreateFlags.AddFlagSet(cmd.FlagSetRPC)
+	nonceCreateFlags.AddFlagSet(cmd.FlagSetSigner)
+	nonceCreateFlags.StringVar(&nonceCreateAuthorityFlag, "authority", "", "Nonce authority, must be the publisher key using it (defaults to the funding key)")
+}
+
+func runNonceCreate(_ *cobra.Command, _ []string) {
+	ctx := context.Background()
+
+	rpcURL, err := cmd.GetRPCFlag()
+	cobra.CheckErr(err)
+	client := solana_rpc.New(rpcURL.String())
+
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(cmd.GetPrivateKeyPaths()[0])
+	cobra.CheckErr(err)
+	authority := payer.PublicKey()
+	if nonceCreateAuthorityFlag != "" {
+		authority, err = solana.PublicKeyFromBase58(nonceCreateAuthorityFlag)
+		cobra.CheckErr(err)
+	}
+
+	// The nonce account key only signs its creation and can be discarded.
+	nonceKey, err := solana.NewRandomPrivateKey()
+	cobra.CheckErr(err)
+
+	rent, err := client.GetMinimumBalanceForRentExemption(ctx, schedule.NonceAccountSize, solana_rpc.CommitmentConfirmed)
+	cobra.CheckErr(err)
+	recent, err := client.GetLatestBlockhash(ctx, solana_rpc.CommitmentConfirmed)
+	cobra.CheckErr(err)
+
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{
+			system.NewCreateAccountInstruction(
+				rent,
+				schedule.NonceAccountSize,
+				solana.SystemProgramID,
+				payer.PublicKey(),
+				nonceKey.PublicKey(),
+			).Build(),
+			system.NewInitializeNonceAccountInstruction(
+				authority,
+				nonceKey.PublicKey(),
+				solana.SysVarRecentBlockHashesPubkey,
+				solana.SysVarRentPubkey,
+			).Build(),
+		},
+		recent.Value.Blockhash,
+		solana.TransactionPayer(payer.PublicKey()),
+	)
+	cobra.CheckErr(err)
+	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		switch key {
+		case payer.PublicKey():
+			return &payer
+		case nonceKey.PublicKey():
+			return &nonceKey
+		}
+		return nil
+	})
+	cobra.CheckErr(err)
+
+	sig, err := client.SendTransactionWithOpts(ctx, tx, false, solana_rpc.CommitmentConfirmed)
+	if err != nil {
+		log.Fatal("Failed to create nonce account", zap.Error(err))
+	}
+	log.Info("Sent nonce account creation",
+		zap.Stringer("account", nonceKey.PublicKey()),
+		zap.Stringer("authority", authority),
+		zap.Uint64("lamports", rent),
+		zap.Stringer("signature", sig))
+	fmt.Println(nonceKey.PublicKey())
+}
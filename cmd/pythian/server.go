@@ -3,15 +3,24 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/gagliardetto/solana-go"
 	solana_rpc "github.com/gagliardetto/solana-go/rpc"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/calendar"
 	"go.blockdaemon.com/pythian/cmd"
+	"go.blockdaemon.com/pythian/history"
 	"go.blockdaemon.com/pythian/jsonrpc"
 	"go.blockdaemon.com/pythian/schedule"
 	pythian_server "go.blockdaemon.com/pythian/server"
@@ -28,8 +37,62 @@ var serverCmd = cobra.Command{
 }
 
 var (
-	serverFlags      = serverCmd.Flags()
-	serverListenFlag string
+	serverFlags                = serverCmd.Flags()
+	serverListenFlag           string
+	serverDecimalToleranceFlag float64
+
+	guardMaxAggDeviationFlag  float64
+	guardMaxPrevDeviationFlag float64
+	guardMaxConfRatioFlag     float64
+	guardNonNegativeFlag      bool
+	guardAllowNegativeFlag    []string
+	guardQuarantineFlag       bool
+	guardQuarantineTTLFlag    time.Duration
+
+	adminTokenFileFlag string
+
+	watchdogMaxSlotsFlag uint64
+
+	heartbeatIntervalFlag uint64
+	heartbeatMaxHoldFlag  uint64
+	heartbeatAccountsFlag []string
+
+	shadowFlag     string
+	shadowFileFlag string
+
+	calendarFileFlag string
+
+	priorityAccountsFlag []string
+	txMaxResubmitsFlag   int
+
+	nonceAccountsFlag []string
+
+	cadenceFlag         string
+	cadenceAccountsFlag []string
+	staleWindowFlag     uint64
+
+	slotWSFlag     []string
+	slotMaxLagFlag uint64
+
+	blockhashMinValidFlag uint64
+
+	sendModeFlag       string
+	tpuFanoutFlag      uint64
+	tpuTransportsFlag  []string
+	sendRPCFlag        []string
+	sendHedgeFlag      int
+	sendHedgeDelayFlag time.Duration
+
+	feeUnitPriceFlag      uint64
+	feeUnitsPerUpdateFlag uint32
+	feeDynamicFlag        bool
+	feePercentileFlag     float64
+	feeFloorFlag          uint64
+	feeCeilingFlag        uint64
+
+	historyAccountsFlag []string
+	historyMaxAgeFlag   time.Duration
+	historyMaxBytesFlag int64
 )
 
 func init() {
@@ -37,9 +100,53 @@ func init() {
 	serverFlags.AddFlagSet(cmd.FlagSetRPC)
 	serverFlags.AddFlagSet(cmd.FlagSetSigner)
 	serverFlags.StringVar(&serverListenFlag, "listen", ":8910", "Listen address")
+	serverFlags.Float64Var(&serverDecimalToleranceFlag, "decimal-tolerance", 0, "Max rounding error when scaling decimal prices, in units of the price exponent")
+	serverFlags.Float64Var(&guardMaxAggDeviationFlag, "guard-max-agg-deviation", 0, "Reject quotes deviating from the on-chain aggregate by more than this ratio (0 to disable)")
+	serverFlags.Float64Var(&guardMaxPrevDeviationFlag, "guard-max-prev-deviation", 0, "Reject quotes deviating from the previous quote by more than this ratio (0 to disable)")
+	serverFlags.Float64Var(&guardMaxConfRatioFlag, "guard-max-conf-ratio", 0, "Reject quotes with a higher conf/price ratio (0 to disable)")
+	serverFlags.BoolVar(&guardNonNegativeFlag, "guard-non-negative", false, "Reject negative prices")
+	serverFlags.StringSliceVar(&guardAllowNegativeFlag, "guard-allow-negative", nil, "Price accounts exempt from --guard-non-negative")
+	serverFlags.BoolVar(&guardQuarantineFlag, "guard-quarantine", false, "Hold rejected quotes for inspection and release via admin_release_quarantined")
+	serverFlags.DurationVar(&guardQuarantineTTLFlag, "guard-quarantine-ttl", 10*time.Minute, "Discard quarantined quotes after this long")
+	serverFlags.Uint64Var(&blockhashMinValidFlag, "blockhash-min-valid-blocks", 30, "Hold back transactions when the block hash expires within this many blocks")
+	serverFlags.StringSliceVar(&slotWSFlag, "slot-ws", nil, "Additional WebSocket URLs to follow slots on, the highest slot wins")
+	serverFlags.Uint64Var(&slotMaxLagFlag, "slot-max-lag", 8, "Demote slot endpoints lagging by more than this many slots")
+	serverFlags.StringSliceVar(&nonceAccountsFlag, "nonce-accounts", nil, "Durable nonce accounts to use instead of recent block hashes, authorized to a publisher key")
+	serverFlags.StringVar(&sendModeFlag, "send-mode", "rpc", "Send transactions via RPC or directly to leader TPUs (rpc, tpu)")
+	serverFlags.StringSliceVar(&tpuTransportsFlag, "tpu-transports", []string{"quic", "udp"}, "Protocols to send to leader TPUs with in --send-mode=tpu (quic, udp)")
+	serverFlags.Uint64Var(&tpuFanoutFlag, "tpu-fanout", 12, "Send to the leaders of this many upcoming slots in --send-mode=tpu")
+	serverFlags.StringSliceVar(&sendRPCFlag, "send-rpc", nil, "RPC URLs to broadcast transactions to (defaults to --rpc)")
+	serverFlags.IntVar(&sendHedgeFlag, "send-hedge", 0, "Send each transaction to this many endpoints at once, more only on failure or delay (0 for all)")
+	serverFlags.DurationVar(&sendHedgeDelayFlag, "send-hedge-delay", 0, "Send to the next endpoints if none succeeded within this time (0 to wait for failures)")
+	serverFlags.StringVar(&cadenceFlag, "cadence", "slot", "When to publish fresh quotes (slot, slots:<n>, interval:<duration>, threshold:<ratio>)")
+	serverFlags.StringArrayVar(&cadenceAccountsFlag, "cadence-account", nil, "Per price account cadence as <account>=<cadence>, can be repeated")
+	serverFlags.Uint64Var(&staleWindowFlag, "stale-window-slots", 32, "Drop buffered quotes not sent within this many slots")
+	serverFlags.IntVar(&txMaxResubmitsFlag, "tx-max-resubmits", 2, "Resubmit updates of expired transactions this many times if still the latest quote")
+	serverFlags.StringSliceVar(&priorityAccountsFlag, "priority-accounts", nil, "Price accounts to pack into the first transactions of a slot, highest priority first")
+	serverFlags.Uint64Var(&feeUnitPriceFlag, "fee-unit-price", 0, "Compute unit price in micro-lamports (initial price if --fee-dynamic)")
+	serverFlags.Uint32Var(&feeUnitsPerUpdateFlag, "fee-units-per-update", 30_000, "Compute units to request per price update")
+	serverFlags.BoolVar(&feeDynamicFlag, "fee-dynamic", false, "Estimate compute unit price from recent prioritization fees")
+	serverFlags.Float64Var(&feePercentileFlag, "fee-percentile", 50, "Percentile of recent prioritization fees to pay")
+	serverFlags.Uint64Var(&feeFloorFlag, "fee-floor", 0, "Min estimated compute unit price in micro-lamports")
+	serverFlags.Uint64Var(&feeCeilingFlag, "fee-ceiling", 0, "Max estimated compute unit price in micro-lamports (0 for no limit)")
+	serverFlags.StringVar(&calendarFileFlag, "calendar-file", "", "Path to market hours calendar config (JSON)")
+	serverFlags.StringVar(&shadowFlag, "shadow", "", "Shadow mode, never send transactions to the cluster (discard, simulate, file)")
+	serverFlags.StringVar(&shadowFileFlag, "shadow-file", "", "Path to write transactions to in --shadow=file mode")
+	serverFlags.Uint64Var(&watchdogMaxSlotsFlag, "stale-after-slots", 0, "Publish unknown status for prices without a fresh quote for this many slots (0 to disable)")
+	serverFlags.Uint64Var(&heartbeatIntervalFlag, "heartbeat-interval-slots", 0, "Republish the last quote after this many slots without a fresh one (0 to disable)")
+	serverFlags.Uint64Var(&heartbeatMaxHoldFlag, "heartbeat-max-hold-slots", 150, "Stop republishing this many slots after the last fresh quote")
+	serverFlags.StringSliceVar(&heartbeatAccountsFlag, "heartbeat-accounts", nil, "Price accounts to republish (all if empty)")
+	serverFlags.AddFlagSet(cmd.FlagSetHistory)
+	serverFlags.StringSliceVar(&historyAccountsFlag, "history-accounts", nil, "Price accounts to record history for (none if empty)")
+	serverFlags.DurationVar(&historyMaxAgeFlag, "history-max-age", history.DefaultOptions().MaxAge, "Delete price history older than this (0 to keep forever)")
+	serverFlags.Int64Var(&historyMaxBytesFlag, "history-max-bytes", 0, "Delete oldest price history beyond this size (0 for no limit)")
+	serverFlags.StringVar(&adminTokenFileFlag, "admin-token-file", "", "Path to file containing bearer token for admin methods (admin methods disabled if unset)")
 }
 
 func runServer(_ *cobra.Command, _ []string) {
+	if shadowFlag != "" {
+		log = log.With(zap.String("shadow", shadowFlag))
+	}
 	log.Info("Initializing")
 	defer log.Info("Shutdown completed")
 
@@ -68,9 +175,12 @@ func runServer(_ *cobra.Command, _ []string) {
 	solanaRPC := solana_rpc.New(solanaRpcUrl.String())
 
 	// Create transaction signer.
-	txSigner, err := signer.NewSigner(cmd.GetPrivateKeyPath(), pythEnv.Program)
+	txSigner, err := signer.NewSigner(cmd.GetPrivateKeyPaths(), pythEnv.Program)
 	cobra.CheckErr(err)
-	log.Info("Signer initialized", zap.Stringer("pubkey", txSigner.Pubkey()))
+	defer txSigner.Close()
+	for _, pubkey := range txSigner.Pubkeys() {
+		log.Info("Signer initialized", zap.Stringer("pubkey", pubkey))
+	}
 
 	// Create recent block hash monitor.
 	log.Info("Starting block hash monitor")
@@ -79,6 +189,7 @@ func runServer(_ *cobra.Command, _ []string) {
 		log.Fatal("Failed to set up blockhash monitor", zap.Error(err))
 	}
 	blockhashes.Log = log.Named("blockhash")
+	blockhashes.MinValidFor = blockhashMinValidFlag
 	group.Go(func() error {
 		defer log.Info("Stopped block hash monitor")
 		blockhashes.Run(ctx)
@@ -87,8 +198,10 @@ func runServer(_ *cobra.Command, _ []string) {
 
 	// Create slot monitor.
 	log.Info("Starting slot monitor")
-	slots := schedule.NewSlotMonitor(solanaWsUrl.String())
+	slots := schedule.NewSlotMonitor(append([]string{solanaWsUrl.String()}, slotWSFlag...)...)
 	slots.Log = log.Named("slots")
+	slots.MaxLag = slotMaxLagFlag
+	slots.Poll = true
 	group.Go(func() error {
 		defer log.Info("Stopped slot monitor")
 		return slots.Run(ctx)
@@ -97,9 +210,212 @@ func runServer(_ *cobra.Command, _ []string) {
 	// Create update buffer.
 	buffer := schedule.NewBuffer()
 
+	// Create heartbeat.
+	if heartbeatIntervalFlag > 0 {
+		heartbeat := schedule.NewHeartbeat(buffer, heartbeatIntervalFlag, heartbeatMaxHoldFlag)
+		heartbeat.Log = log.Named("heartbeat")
+		if len(heartbeatAccountsFlag) > 0 {
+			heartbeat.Accounts = make(map[solana.PublicKey]bool)
+			for _, acc := range heartbeatAccountsFlag {
+				key, err := solana.PublicKeyFromBase58(acc)
+				cobra.CheckErr(err)
+				heartbeat.Accounts[key] = true
+			}
+		}
+		buffer.Heartbeat = heartbeat
+		group.Go(func() error {
+			defer log.Info("Stopped heartbeat")
+			heartbeat.Run(ctx, slots)
+			return nil
+		})
+	}
+
+	// Create staleness watchdog.
+	if watchdogMaxSlotsFlag > 0 {
+		watchdog := schedule.NewWatchdog(buffer, watchdogMaxSlotsFlag)
+		watchdog.Log = log.Named("watchdog")
+		buffer.Watchdog = watchdog
+		group.Go(func() error {
+			defer log.Info("Stopped watchdog")
+			watchdog.Run(ctx, slots)
+			return nil
+		})
+	}
+
+	// Create publish stats tracker.
+	stats := schedule.NewPublishStats()
+
 	// Create scheduler.
 	sched := schedule.NewScheduler(buffer, blockhashes, txSigner, solanaRPC)
 	sched.Log = log.Named("scheduler")
+	sched.Stats = stats
+	sched.StaleSlots = staleWindowFlag
+	if cadenceFlag != "slot" || len(cadenceAccountsFlag) > 0 {
+		def, err := schedule.ParseCadence(cadenceFlag)
+		cobra.CheckErr(err)
+		sched.Cadence = schedule.NewCadencePolicy(def)
+		for _, spec := range cadenceAccountsFlag {
+			parts := strings.SplitN(spec, "=", 2)
+			if len(parts) != 2 {
+				cobra.CheckErr(fmt.Errorf("invalid --cadence-account %q, expected <account>=<cadence>", spec))
+			}
+			key, err := solana.PublicKeyFromBase58(parts[0])
+			cobra.CheckErr(err)
+			cadence, err := schedule.ParseCadence(parts[1])
+			cobra.CheckErr(err)
+			sched.Cadence.Accounts[key] = cadence
+		}
+	}
+	sched.Packer.Priority = make(map[solana.PublicKey]int)
+	for i, acc := range priorityAccountsFlag {
+		key, err := solana.PublicKeyFromBase58(acc)
+		cobra.CheckErr(err)
+		sched.Packer.Priority[key] = len(priorityAccountsFlag) - i
+	}
+	// Pick how transactions reach the cluster, unless in shadow mode.
+	switch {
+	case shadowFlag != "":
+	case sendModeFlag == "tpu":
+		if len(sendRPCFlag) > 0 {
+			log.Fatal("--send-rpc is not supported with --send-mode=tpu")
+		}
+		if len(tpuTransportsFlag) == 0 {
+			log.Fatal("--send-mode=tpu requires at least one of --tpu-transports")
+		}
+		var transports []schedule.TPUTransport
+		for _, name := range tpuTransportsFlag {
+			switch name {
+			case "udp":
+				transport, err := schedule.NewUDPTransport()
+				if err != nil {
+					log.Fatal("Failed to open UDP socket", zap.Error(err))
+				}
+				defer transport.Close()
+				transports = append(transports, transport)
+			case "quic":
+				transport, err := schedule.NewQUICTransport()
+				if err != nil {
+					log.Fatal("Failed to set up QUIC transport", zap.Error(err))
+				}
+				defer transport.Close()
+				transports = append(transports, transport)
+			default:
+				log.Fatal("Unknown TPU transport", zap.String("transport", name))
+			}
+		}
+		tpu := schedule.NewTPUSender(solanaRPC, slots, transports...)
+		tpu.Log = log.Named("tpu")
+		tpu.Fanout = tpuFanoutFlag
+		sched.Sender = tpu
+		group.Go(func() error {
+			defer log.Info("Stopped TPU sender")
+			tpu.Run(ctx)
+			return nil
+		})
+	case sendModeFlag != "rpc":
+		log.Fatal("Unknown send mode", zap.String("send_mode", sendModeFlag))
+	case len(sendRPCFlag) > 0:
+		endpoints := make([]schedule.Endpoint, len(sendRPCFlag))
+		names := schedule.EndpointNames(sendRPCFlag)
+		for i, rawURL := range sendRPCFlag {
+			if _, err := url.Parse(rawURL); err != nil {
+				log.Fatal("Invalid send RPC URL", zap.Error(err))
+			}
+			endpoints[i] = schedule.Endpoint{
+				Name:   names[i],
+				Sender: schedule.NewRPCSender(solana_rpc.New(rawURL)),
+			}
+		}
+		broadcast := schedule.NewBroadcastSender(endpoints)
+		broadcast.Log = log.Named("broadcast")
+		broadcast.HedgeCount = sendHedgeFlag
+		broadcast.HedgeDelay = sendHedgeDelayFlag
+		sched.Sender = broadcast
+	}
+	switch shadowFlag {
+	case "":
+	case "discard":
+		sched.Sender = schedule.DiscardSender{}
+	case "simulate":
+		simulator := schedule.NewSimulateSender(solanaRPC)
+		simulator.Log = log.Named("simulate")
+		sched.Sender = simulator
+	case "file":
+		if shadowFileFlag == "" {
+			log.Fatal("Missing --shadow-file")
+		}
+		f, err := os.OpenFile(shadowFileFlag, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatal("Failed to open shadow file", zap.Error(err))
+		}
+		defer f.Close()
+		sched.Sender = schedule.NewFileSender(f)
+	default:
+		log.Fatal("Unknown shadow mode", zap.String("shadow", shadowFlag))
+	}
+	if len(nonceAccountsFlag) > 0 {
+		accounts := make([]solana.PublicKey, len(nonceAccountsFlag))
+		for i, acc := range nonceAccountsFlag {
+			accounts[i], err = solana.PublicKeyFromBase58(acc)
+			cobra.CheckErr(err)
+		}
+		log.Info("Starting nonce monitor")
+		nonces, err := schedule.NewNonceMonitor(ctx, solanaRPC, solanaWsUrl.String(), accounts)
+		if err != nil {
+			log.Fatal("Failed to set up nonce monitor", zap.Error(err))
+		}
+		nonces.Log = log.Named("nonce")
+		for _, pubkey := range txSigner.Pubkeys() {
+			if !nonces.Has(pubkey) {
+				log.Warn("No nonce account authorized to publisher", zap.Stringer("pubkey", pubkey))
+			}
+		}
+		sched.Nonces = nonces
+		txSigner.AllowNonceAdvance()
+		group.Go(func() error {
+			defer log.Info("Stopped nonce monitor")
+			return nonces.Run(ctx)
+		})
+	}
+	if feeUnitPriceFlag > 0 || feeDynamicFlag {
+		fees := schedule.NewPriorityFees(solanaRPC, pythEnv.Program, feeUnitPriceFlag)
+		fees.Log = log.Named("fees")
+		fees.UnitsPerUpdate = feeUnitsPerUpdateFlag
+		fees.Percentile = feePercentileFlag
+		fees.Floor = feeFloorFlag
+		fees.Ceiling = feeCeilingFlag
+		sched.Fees = fees
+		txSigner.AllowPrograms(schedule.ComputeBudgetProgramID)
+		if feeDynamicFlag {
+			group.Go(func() error {
+				defer log.Info("Stopped priority fee estimator")
+				fees.Run(ctx)
+				return nil
+			})
+		}
+	}
+	if shadowFlag != "" {
+		log.Warn("Shadow mode enabled, not sending transactions to the cluster")
+	}
+
+	// Create transaction status tracker.
+	// Shadow transactions never land, so there is nothing to track.
+	var tracker *schedule.TxTracker
+	if shadowFlag == "" {
+		tracker = schedule.NewTxTracker(solanaRPC, slots)
+		tracker.Log = log.Named("tracker")
+		tracker.Buffer = buffer
+		tracker.MaxResubmits = txMaxResubmitsFlag
+		tracker.Stats = stats
+		tracker.BlockHash = blockhashes
+		tracker.Nonces = sched.Nonces
+		sched.Tracker = tracker
+		group.Go(func() error {
+			defer log.Info("Stopped transaction tracker")
+			tracker.Run(ctx)
+			return nil
+		})
+	}
 	log.Info("Starting publish scheduler")
 	group.Go(func() error {
 		defer log.Info("Stopped publish scheduler")
@@ -107,9 +423,97 @@ func runServer(_ *cobra.Command, _ []string) {
 		return nil
 	})
 
+	// Create price account cache.
+	prices := pythian_server.NewPriceCache(pythClient)
+	prices.Log = log.Named("prices")
+	prices.OnUpdate(func(entry pyth.PriceAccountEntry) {
+		stats.ObservePrice(entry, slots.Slot())
+	})
+	group.Go(func() error {
+		defer log.Info("Stopped price cache")
+		return prices.Run(ctx)
+	})
+
+	// Create product catalog watcher.
+	products := pythian_server.NewProductWatcher(pythClient, prices)
+	products.Log = log.Named("products")
+	group.Go(func() error {
+		defer log.Info("Stopped product watcher")
+		return products.Run(ctx)
+	})
+
+	// Create price history store.
+	var historyStore *history.Store
+	if *cmd.FlagHistoryDir != "" {
+		opts := history.DefaultOptions()
+		opts.MaxAge = historyMaxAgeFlag
+		opts.MaxBytes = historyMaxBytesFlag
+		historyStore, err = history.Open(*cmd.FlagHistoryDir, opts)
+		if err != nil {
+			log.Fatal("Failed to open price history", zap.Error(err))
+		}
+		defer historyStore.Close()
+		recorder := history.NewRecorder(historyStore)
+		recorder.Log = log.Named("history")
+		recorder.Accounts = make(map[solana.PublicKey]bool)
+		for _, acc := range historyAccountsFlag {
+			key, err := solana.PublicKeyFromBase58(acc)
+			cobra.CheckErr(err)
+			recorder.Accounts[key] = true
+		}
+		if len(recorder.Accounts) > 0 {
+			prices.OnUpdate(recorder.ObservePrice)
+			log.Info("Recording price history",
+				zap.String("dir", *cmd.FlagHistoryDir),
+				zap.Int("accounts", len(recorder.Accounts)))
+		} else {
+			log.Warn("No --history-accounts given, only serving existing price history")
+		}
+	}
+
 	// Create Pythian JSON-RPC handler.
-	rpc := pythian_server.NewHandler(pythClient, buffer, txSigner.Pubkey(), slots)
+	rpc := pythian_server.NewHandler(pythClient, buffer, txSigner.Pubkeys(), slots, prices)
 	rpc.Log = log.Named("server")
+	rpc.DecimalTolerance = serverDecimalToleranceFlag
+	rpc.Guard = newGuard(prices)
+	rpc.Stats = stats
+	rpc.Products = products
+	rpc.History = historyStore
+	rpc.Tracker = tracker
+	if calendarFileFlag != "" {
+		config, err := calendar.Load(calendarFileFlag)
+		if err != nil {
+			log.Fatal("Failed to load calendar", zap.Error(err))
+		}
+		hours := pythian_server.NewMarketHours(config, pythClient, buffer, slots)
+		hours.Log = log.Named("calendar")
+		products.OnSync(hours.LoadCatalog)
+		products.Subscribe(hours.ApplyProductEvent)
+		rpc.MarketHours = hours
+		group.Go(func() error {
+			defer log.Info("Stopped market hours")
+			hours.Run(ctx)
+			return nil
+		})
+	}
+
+	// Create admin JSON-RPC methods.
+	var adminToken string
+	if adminTokenFileFlag != "" {
+		tokenBytes, err := os.ReadFile(adminTokenFileFlag)
+		cobra.CheckErr(err)
+		adminToken = strings.TrimSpace(string(tokenBytes))
+		if adminToken == "" {
+			log.Fatal("Admin token file is empty")
+		}
+		admin := pythian_server.NewAdminHandler(buffer, sched, slots, blockhashes)
+		admin.Log = log.Named("admin")
+		admin.LogLevel = cmd.AtomicLogLevel
+		admin.Guard = rpc.Guard
+		admin.Program = pythEnv.Program
+		admin.Register(rpc.Mux)
+		log.Info("Admin methods enabled")
+	}
 
 	// Start HTTP server.
 	log.Info("Starting HTTP server", zap.String("listen", serverListenFlag))
@@ -117,8 +521,15 @@ func runServer(_ *cobra.Command, _ []string) {
 		defer log.Info("Stopped HTTP server")
 
 		rpcServer := jsonrpc.NewServer(rpc)
-		http.Handle("/", rpcServer)
-		http.Handle("/metrics", promhttp.Handler())
+		http.Handle("/", jsonrpc.BearerAuth(adminToken, rpcServer))
+		var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+		if shadowFlag != "" {
+			gatherer = cmd.LabeledGatherer(gatherer, "shadow", shadowFlag)
+		}
+		http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}),
+		))
 
 		server := http.Server{Addr: serverListenFlag}
 		go func() {
@@ -140,3 +551,26 @@ func runServer(_ *cobra.Command, _ []string) {
 		log.Error("Crashed", zap.Error(err))
 	}
 }
+
+// newGuard returns a price update guard as configured by flags, or nil if no rules are enabled.
+func newGuard(prices *pythian_server.PriceCache) *pythian_server.Guard {
+	if guardMaxAggDeviationFlag == 0 && guardMaxPrevDeviationFlag == 0 &&
+		guardMaxConfRatioFlag == 0 && !guardNonNegativeFlag {
+		return nil
+	}
+	guard := pythian_server.NewGuard(prices)
+	guard.Log = log.Named("guard")
+	guard.MaxAggDeviation = guardMaxAggDeviationFlag
+	guard.MaxPrevDeviation = guardMaxPrevDeviationFlag
+	guard.MaxConfRatio = guardMaxConfRatioFlag
+	guard.NonNegative = guardNonNegativeFlag
+	guard.AllowNegative = make(map[solana.PublicKey]bool)
+	for _, acc := range guardAllowNegativeFlag {
+		key, err := solana.PublicKeyFromBase58(acc)
+		cobra.CheckErr(err)
+		guard.AllowNegative[key] = true
+	}
+	guard.Quarantine = guardQuarantineFlag
+	guard.QuarantineTTL = guardQuarantineTTLFlag
+	return guard
+}
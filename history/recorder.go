@@ -0,0 +1,91 @@
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+	"go.uber.org/zap"
+)
+
+// Recorder writes aggregate and component price updates to a store.
+type Recorder struct {
+	Log      *zap.Logger
+	Accounts map[solana.PublicKey]bool // price accounts to record
+
+	store *Store
+	lock  sync.Mutex
+	last  map[recordKey]uint64 // last recorded publish slot
+}
+
+type recordKey struct {
+	account   solana.PublicKey
+	publisher solana.PublicKey
+}
+
+// NewRecorder creates a new recorder writing to the given store.
+func NewRecorder(store *Store) *Recorder {
+	return &Recorder{
+		Log:   zap.NewNop(),
+		store: store,
+		last:  make(map[recordKey]uint64),
+	}
+}
+
+// ObservePrice records all updates in the given price account state that were not recorded yet.
+func (r *Recorder) ObservePrice(entry pyth.PriceAccountEntry) {
+	if !r.Accounts[entry.Pubkey] {
+		return
+	}
+
+	now := time.Now().UTC()
+	var records []Record
+	r.lock.Lock()
+	if r.isNew(entry.Pubkey, solana.PublicKey{}, entry.Agg.PubSlot) {
+		records = append(records, newRecord(entry.Pubkey, solana.PublicKey{}, &entry.Agg, now))
+	}
+	for _, comp := range entry.Components {
+		if comp.Publisher.IsZero() {
+			continue
+		}
+		if r.isNew(entry.Pubkey, comp.Publisher, comp.Latest.PubSlot) {
+			records = append(records, newRecord(entry.Pubkey, comp.Publisher, &comp.Latest, now))
+		}
+	}
+	r.lock.Unlock()
+
+	if len(records) == 0 {
+		return
+	}
+	if err := r.store.Append(records...); err != nil {
+		r.Log.Warn("Failed to write price history", zap.Error(err))
+	}
+}
+
+// isNew returns whether the given update was not recorded yet and remembers it.
+//
+// Must be called with lock held.
+func (r *Recorder) isNew(account solana.PublicKey, publisher solana.PublicKey, slot uint64) bool {
+	if slot == 0 {
+		return false
+	}
+	key := recordKey{account: account, publisher: publisher}
+	if r.last[key] >= slot {
+		return false
+	}
+	r.last[key] = slot
+	return true
+}
+
+func newRecord(account solana.PublicKey, publisher solana.PublicKey, info *pyth.PriceInfo, now time.Time) Record {
+	return Record{
+		Account:   account,
+		Publisher: publisher,
+		Slot:      info.PubSlot,
+		Time:      now,
+		Price:     info.Price,
+		Conf:      info.Conf,
+		Status:    info.Status,
+	}
+}
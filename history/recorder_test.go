@@ -0,0 +1,43 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+func TestRecorder_Accounts(t *testing.T) {
+	store, err := Open(t.TempDir(), DefaultOptions())
+	require.NoError(t, err)
+	defer store.Close()
+	recorder := NewRecorder(store)
+
+	recorded, ignored := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	observe := func(account solana.PublicKey, slot uint64) {
+		recorder.ObservePrice(pyth.PriceAccountEntry{
+			Pubkey: account,
+			PriceAccount: &pyth.PriceAccount{
+				Agg: pyth.PriceInfo{Price: 100, Conf: 1, Status: pyth.PriceStatusTrading, PubSlot: slot},
+			},
+		})
+	}
+
+	// Nothing is recorded without accounts.
+	observe(recorded, 10)
+	recorder.Accounts = map[solana.PublicKey]bool{recorded: true}
+	observe(recorded, 11)
+	observe(recorded, 11)
+	observe(ignored, 11)
+
+	var records []Record
+	require.NoError(t, store.Scan(Query{}, func(r Record) bool {
+		records = append(records, r)
+		return true
+	}))
+	require.Len(t, records, 1)
+	assert.Equal(t, recorded, records[0].Account)
+	assert.Equal(t, uint64(11), records[0].Slot)
+}
@@ -0,0 +1,339 @@
+// Package history stores price updates in an append-only on-disk log.
+//
+// The log is split into segment files of fixed-size records.
+// Old segments are deleted according to retention limits.
+package history
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Record is a single aggregate or component price update.
+type Record struct {
+	Account   solana.PublicKey // price account
+	Publisher solana.PublicKey // zero for aggregate price
+	Slot      uint64           // publish slot
+	Time      time.Time        // time of observation
+	Price     int64
+	Conf      uint64
+	Status    uint32
+}
+
+// IsAggregate returns whether the record is an aggregate price, as opposed to a publisher component.
+func (r *Record) IsAggregate() bool {
+	return r.Publisher.IsZero()
+}
+
+func (r Record) MarshalJSON() ([]byte, error) {
+	type record struct {
+		Account   string    `json:"account"`
+		Publisher string    `json:"publisher,omitempty"`
+		Slot      uint64    `json:"slot"`
+		Time      time.Time `json:"time"`
+		Price     int64     `json:"price"`
+		Conf      uint64    `json:"conf"`
+		Status    uint32    `json:"status"`
+	}
+	out := record{
+		Account: r.Account.String(),
+		Slot:    r.Slot,
+		Time:    r.Time,
+		Price:   r.Price,
+		Conf:    r.Conf,
+		Status:  r.Status,
+	}
+	if !r.IsAggregate() {
+		out.Publisher = r.Publisher.String()
+	}
+	return json.Marshal(&out)
+}
+
+const recordSize = 32 + 32 + 8 + 8 + 8 + 8 + 4
+
+func (r *Record) marshal(buf []byte) {
+	copy(buf[0:32], r.Account[:])
+	copy(buf[32:64], r.Publisher[:])
+	binary.LittleEndian.PutUint64(buf[64:72], r.Slot)
+	binary.LittleEndian.PutUint64(buf[72:80], uint64(r.Time.UnixNano()))
+	binary.LittleEndian.PutUint64(buf[80:88], uint64(r.Price))
+	binary.LittleEndian.PutUint64(buf[88:96], r.Conf)
+	binary.LittleEndian.PutUint32(buf[96:100], r.Status)
+}
+
+func (r *Record) unmarshal(buf []byte) {
+	copy(r.Account[:], buf[0:32])
+	copy(r.Publisher[:], buf[32:64])
+	r.Slot = binary.LittleEndian.Uint64(buf[64:72])
+	r.Time = time.Unix(0, int64(binary.LittleEndian.Uint64(buf[72:80]))).UTC()
+	r.Price = int64(binary.LittleEndian.Uint64(buf[80:88]))
+	r.Conf = binary.LittleEndian.Uint64(buf[88:96])
+	r.Status = binary.LittleEndian.Uint32(buf[96:100])
+}
+
+// Options configures a store.
+type Options struct {
+	SegmentSize     int64         // max bytes per segment file
+	SegmentDuration time.Duration // max time span per segment file
+	MaxAge          time.Duration // delete segments older than this, zero to keep forever
+	MaxBytes        int64         // delete oldest segments when exceeding this size on rollover, zero for no limit
+}
+
+// DefaultOptions returns the default store options.
+func DefaultOptions() Options {
+	return Options{
+		SegmentSize:     64 << 20,
+		SegmentDuration: time.Hour,
+		MaxAge:          7 * 24 * time.Hour,
+		MaxBytes:        0,
+	}
+}
+
+// Query selects records from the store. Zero values match everything.
+type Query struct {
+	Account   solana.PublicKey
+	Publisher *solana.PublicKey // nil matches all, zero matches aggregates only
+	FromSlot  uint64
+	ToSlot    uint64
+	FromTime  time.Time
+	ToTime    time.Time
+}
+
+func (q *Query) matches(r *Record) bool {
+	switch {
+	case !q.Account.IsZero() && !q.Account.Equals(r.Account):
+		return false
+	case q.Publisher != nil && !q.Publisher.Equals(r.Publisher):
+		return false
+	case q.FromSlot != 0 && r.Slot < q.FromSlot:
+		return false
+	case q.ToSlot != 0 && r.Slot > q.ToSlot:
+		return false
+	case !q.FromTime.IsZero() && r.Time.Before(q.FromTime):
+		return false
+	case !q.ToTime.IsZero() && r.Time.After(q.ToTime):
+		return false
+	default:
+		return true
+	}
+}
+
+// Store is an append-only price history store.
+type Store struct {
+	dir  string
+	opts Options
+
+	lock     sync.Mutex
+	segments []segment // oldest first, last one is active
+	file     *os.File
+	writer   *bufio.Writer
+}
+
+type segment struct {
+	start time.Time
+	path  string
+	size  int64
+}
+
+const segmentExt = ".seg"
+
+// Open opens or creates a store in the given directory.
+func Open(dir string, opts Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, opts: opts}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, segment{
+			start: time.Unix(0, nanos),
+			path:  filepath.Join(dir, name),
+			size:  info.Size() - info.Size()%recordSize, // ignore torn writes
+		})
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].start.Before(s.segments[j].start)
+	})
+	if err := s.enforceRetention(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append writes records to the end of the log.
+func (s *Store) Append(records ...Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	if err := s.maybeRoll(now); err != nil {
+		return err
+	}
+	active := &s.segments[len(s.segments)-1]
+	var buf [recordSize]byte
+	for i := range records {
+		records[i].marshal(buf[:])
+		if _, err := s.writer.Write(buf[:]); err != nil {
+			s.rollback(active.size)
+			return err
+		}
+	}
+	if err := s.writer.Flush(); err != nil {
+		s.rollback(active.size)
+		return err
+	}
+	active.size += int64(len(records)) * recordSize
+	return nil
+}
+
+// rollback discards a failed write by truncating the active segment to the given record boundary.
+//
+// If the segment cannot be truncated, it is closed and the next append starts a new one,
+// which leaves a torn record at the end of the old segment, ignored when reading.
+func (s *Store) rollback(size int64) {
+	if err := s.file.Truncate(size); err == nil {
+		s.writer.Reset(s.file)
+		return
+	}
+	_ = s.file.Close()
+	s.file = nil
+}
+
+func (s *Store) maybeRoll(now time.Time) error {
+	if s.file != nil {
+		active := s.segments[len(s.segments)-1]
+		if active.size < s.opts.SegmentSize && now.Sub(active.start) < s.opts.SegmentDuration {
+			return nil
+		}
+		if err := s.file.Close(); err != nil {
+			return err
+		}
+		s.file = nil
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", now.UnixNano(), segmentExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file = f
+	s.writer = bufio.NewWriter(f)
+	s.segments = append(s.segments, segment{start: now, path: path})
+	return s.enforceRetention(now)
+}
+
+// enforceRetention deletes segments exceeding the retention limits, except the active one.
+func (s *Store) enforceRetention(now time.Time) error {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	for len(s.segments) > 1 {
+		oldest, next := s.segments[0], s.segments[1]
+		expired := s.opts.MaxAge > 0 && now.Sub(next.start) > s.opts.MaxAge
+		oversize := s.opts.MaxBytes > 0 && total > s.opts.MaxBytes
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= oldest.size
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+// Scan calls fn for each record matching the query, in order of insertion.
+// Stops early if fn returns false.
+//
+// Records appended after Scan was called are not visited.
+// Scan does not block appends, so fn may take its time.
+func (s *Store) Scan(q Query, fn func(Record) bool) error {
+	s.lock.Lock()
+	segments := make([]segment, len(s.segments))
+	copy(segments, s.segments)
+	s.lock.Unlock()
+
+	for i, seg := range segments {
+		// Skip segments outside of the time range.
+		if !q.ToTime.IsZero() && seg.start.After(q.ToTime) {
+			break
+		}
+		if !q.FromTime.IsZero() && i+1 < len(segments) && segments[i+1].start.Before(q.FromTime) {
+			continue
+		}
+		more, err := scanSegment(seg, &q, fn)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+func scanSegment(seg segment, q *Query, fn func(Record) bool) (bool, error) {
+	f, err := os.Open(seg.path)
+	if os.IsNotExist(err) {
+		return true, nil // deleted by retention since the scan started
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	rd := bufio.NewReader(io.LimitReader(f, seg.size))
+	var buf [recordSize]byte
+	for {
+		if _, err := io.ReadFull(rd, buf[:]); err == io.EOF {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+		var r Record
+		r.unmarshal(buf[:])
+		if q.matches(&r) && !fn(r) {
+			return false, nil
+		}
+	}
+}
+
+// Close closes the active segment file.
+func (s *Store) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
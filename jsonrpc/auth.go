@@ -0,0 +1,42 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+type authKey struct{}
+
+// BearerAuth marks HTTP requests carrying the given bearer token as authorized.
+//
+// Unauthorized requests are still passed on, but get rejected by handlers wrapped in RequireAuth.
+func BearerAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var authorized bool
+		header := req.Header.Get("authorization")
+		if strings.HasPrefix(header, "Bearer ") && token != "" {
+			given := strings.TrimPrefix(header, "Bearer ")
+			authorized = subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+		}
+		ctx := context.WithValue(req.Context(), authKey{}, authorized)
+		next.ServeHTTP(rw, req.WithContext(ctx))
+	})
+}
+
+// IsAuthorized returns whether the request context was authorized by BearerAuth.
+func IsAuthorized(ctx context.Context) bool {
+	authorized, _ := ctx.Value(authKey{}).(bool)
+	return authorized
+}
+
+// RequireAuth wraps a handler to only serve authorized requests.
+func RequireAuth(h Handler) Handler {
+	return HandleFunc(func(ctx context.Context, req Request, callback Requester) *Response {
+		if !IsAuthorized(ctx) {
+			return NewErrorStringResponse(req.ID, ErrCodeUnauthorized, "Unauthorized")
+		}
+		return h.ServeJSONRPC(ctx, req, callback)
+	})
+}
@@ -0,0 +1,52 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBearerAuth(t *testing.T) {
+	mux := NewMux()
+	mux.Handle("admin", RequireAuth(HandleFunc(func(_ context.Context, req Request, _ Requester) *Response {
+		return NewResultResponse(req.ID, "ok")
+	})))
+	handler := BearerAuth("secret", NewServer(mux))
+
+	cases := []struct {
+		name   string
+		header string
+		result string
+	}{
+		{
+			name:   "authorized",
+			header: "Bearer secret",
+			result: `{"jsonrpc":"2.0","id":1,"result":"ok"}`,
+		},
+		{
+			name:   "wrong token",
+			header: "Bearer wrong",
+			result: `{"jsonrpc":"2.0","id":1,"result":null,"error":{"code":-32003,"message":"Unauthorized"}}`,
+		},
+		{
+			name:   "missing token",
+			result: `{"jsonrpc":"2.0","id":1,"result":null,"error":{"code":-32003,"message":"Unauthorized"}}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"admin"}`))
+			if tc.header != "" {
+				req.Header.Set("authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.result, rec.Body.String())
+		})
+	}
+}
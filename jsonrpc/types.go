@@ -33,6 +33,7 @@ const (
 	ErrCodeParse          = -32700
 	ErrCodeMethodNotFound = -32601
 	ErrCodeInvalidParams  = -32601
+	ErrCodeUnauthorized   = -32003
 )
 
 func NewResultResponse(id interface{}, result interface{}) *Response {
@@ -69,6 +70,15 @@ func NewInvalidParamsResponse(id interface{}) *Response {
 	})
 }
 
+// NewInvalidParamsErrorResponse is like NewInvalidParamsResponse, but carries an error message.
+func NewInvalidParamsErrorResponse(id interface{}, err error) *Response {
+	return NewErrorResponse(id, Error{
+		Code:    ErrCodeInvalidParams,
+		Message: "Invalid Params",
+		Data:    err.Error(),
+	})
+}
+
 func newResponse(id interface{}, result interface{}, error *Error) *Response {
 	if id == nil {
 		return nil
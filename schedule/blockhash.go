@@ -7,16 +7,57 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 	"go.uber.org/zap"
 )
 
+// ErrBlockHashExpiring is returned when the latest known block hash is too close to expiry to use.
+var ErrBlockHashExpiring = errors.New("recent block hash about to expire")
+
+// blockTime is the target block time, used to estimate the block height between polls.
+const blockTime = 400 * time.Millisecond
+
+// BlockHash is a recent block hash and its expiry.
+type BlockHash struct {
+	Blockhash            solana.Hash
+	LastValidBlockHeight uint64
+	Fetched              time.Time
+
+	BlockHeight uint64        // last observed block height
+	Observed    time.Time     // time of observing BlockHeight
+	BlockTime   time.Duration // observed time per block
+}
+
+// EstimateBlockHeight extrapolates the current block height from the last observed height.
+func (h *BlockHash) EstimateBlockHeight(now time.Time) uint64 {
+	if now.Before(h.Observed) || h.BlockTime <= 0 {
+		return h.BlockHeight
+	}
+	return h.BlockHeight + uint64(now.Sub(h.Observed)/h.BlockTime)
+}
+
+// RemainingBlocks returns the estimated number of blocks until the hash expires.
+func (h *BlockHash) RemainingBlocks(now time.Time) uint64 {
+	height := h.EstimateBlockHeight(now)
+	if height >= h.LastValidBlockHeight {
+		return 0
+	}
+	return h.LastValidBlockHeight - height
+}
+
+// BlockHashMonitor polls recent block hashes and the block height they expire at.
+//
+// The block height is polled along with each hash, even if fetching the hash failed,
+// and the time per block is measured between polls to estimate the height in between.
 type BlockHashMonitor struct {
 	client *rpc.Client
 	hash   atomic.Value
+	now    func() time.Time
 
-	Log      *zap.Logger
-	Interval time.Duration
+	Log         *zap.Logger
+	Interval    time.Duration
+	MinValidFor uint64 // min remaining blocks for a hash to be handed out
 }
 
 // NewBlockHashMonitor creates a new unstarted monitor for recent block hashes.
@@ -24,9 +65,11 @@ type BlockHashMonitor struct {
 // It also fetches one hash to start out during the lifetime of the given context.
 func NewBlockHashMonitor(ctx context.Context, client *rpc.Client) (*BlockHashMonitor, error) {
 	monitor := &BlockHashMonitor{
-		client:   client,
-		Log:      zap.NewNop(),
-		Interval: 2 * time.Second,
+		client:      client,
+		now:         time.Now,
+		Log:         zap.NewNop(),
+		Interval:    2 * time.Second,
+		MinValidFor: 30,
 	}
 	if err := monitor.tick(ctx); err != nil {
 		return nil, fmt.Errorf("failed to get initial recent block hash: %w", err)
@@ -47,25 +90,95 @@ func (b *BlockHashMonitor) Run(ctx context.Context) {
 				b.Log.Warn("Failed to get recent block hash", zap.Error(err))
 			}
 			cancel()
+			b.observe(b.now())
 		}
 	}
 }
 
 func (b *BlockHashMonitor) tick(ctx context.Context) error {
-	res, err := b.client.GetRecentBlockhash(ctx, rpc.CommitmentConfirmed)
+	height, err := b.client.GetBlockHeight(ctx, rpc.CommitmentConfirmed)
 	if err != nil {
 		return err
 	}
-	if res == nil || res.Value == nil {
-		return errors.New("getRecentBlockHash() returned nil")
+	now := b.now()
+	prev, _ := b.hash.Load().(*BlockHash)
+	var hash BlockHash
+	if prev != nil {
+		hash = *prev
+		hash.BlockTime = measureBlockTime(prev, height, now)
+	} else {
+		hash.BlockTime = blockTime
+	}
+	hash.BlockHeight, hash.Observed = height, now
+
+	res, err := b.client.GetLatestBlockhash(ctx, rpc.CommitmentConfirmed)
+	if err == nil && (res == nil || res.Value == nil) {
+		err = errors.New("getLatestBlockhash() returned nil")
+	}
+	if err != nil {
+		if prev != nil {
+			// Keep the old hash, but with a corrected expiry estimate.
+			b.hash.Store(&hash)
+		}
+		return err
 	}
-	b.Log.Debug("Updated recent block hash", zap.Stringer("blockhash", &res.Value.Blockhash))
-	b.hash.Store(res.Value)
+	hash.Blockhash = res.Value.Blockhash
+	hash.LastValidBlockHeight = res.Value.LastValidBlockHeight
+	hash.Fetched = now
+	b.Log.Debug("Updated recent block hash",
+		zap.Stringer("blockhash", &hash.Blockhash),
+		zap.Uint64("last_valid_block_height", hash.LastValidBlockHeight),
+		zap.Uint64("block_height", height),
+		zap.Duration("block_time", hash.BlockTime))
+	b.hash.Store(&hash)
 	metricBlockhashUpdates.Inc()
 	return nil
 }
 
-// GetRecentBlockHash returns the latest cached "recent blockhash" value.
-func (b *BlockHashMonitor) GetRecentBlockHash() *rpc.BlockhashResult {
-	return b.hash.Load().(*rpc.BlockhashResult)
+// measureBlockTime updates the time per block with the blocks produced since the previous observation.
+func measureBlockTime(prev *BlockHash, height uint64, now time.Time) time.Duration {
+	const weight = 0.2 // of the latest sample
+	if height <= prev.BlockHeight || !now.After(prev.Observed) {
+		return prev.BlockTime
+	}
+	sample := now.Sub(prev.Observed) / time.Duration(height-prev.BlockHeight)
+	return time.Duration(weight*float64(sample) + (1-weight)*float64(prev.BlockTime))
+}
+
+func (b *BlockHashMonitor) observe(now time.Time) {
+	hash := b.Latest()
+	metricBlockhashAge.Set(now.Sub(hash.Fetched).Seconds())
+	metricBlockhashRemaining.Set(float64(hash.RemainingBlocks(now)))
+}
+
+// Latest returns the latest cached block hash, regardless of expiry.
+func (b *BlockHashMonitor) Latest() *BlockHash {
+	return b.hash.Load().(*BlockHash)
+}
+
+// GetRecentBlockHash returns the latest cached block hash.
+//
+// Returns ErrBlockHashExpiring if it is valid for fewer than MinValidFor blocks.
+func (b *BlockHashMonitor) GetRecentBlockHash() (solana.Hash, error) {
+	hash, err := b.recent()
+	if err != nil {
+		return solana.Hash{}, err
+	}
+	return hash.Blockhash, nil
+}
+
+// recent returns the latest cached block hash along with its expiry.
+//
+// Returns ErrBlockHashExpiring if it is valid for fewer than MinValidFor blocks.
+func (b *BlockHashMonitor) recent() (*BlockHash, error) {
+	hash := b.Latest()
+	if hash.RemainingBlocks(b.now()) < b.MinValidFor {
+		return nil, ErrBlockHashExpiring
+	}
+	return hash, nil
+}
+
+// EstimateBlockHeight extrapolates the current block height from the latest observation.
+func (b *BlockHashMonitor) EstimateBlockHeight() uint64 {
+	return b.Latest().EstimateBlockHeight(b.now())
 }
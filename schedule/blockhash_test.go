@@ -0,0 +1,143 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBlockHash_EstimateBlockHeight(t *testing.T) {
+	observed := time.Unix(1_700_000_000, 0)
+	hash := BlockHash{
+		LastValidBlockHeight: 1150,
+		BlockHeight:          1000,
+		Observed:             observed,
+		BlockTime:            500 * time.Millisecond,
+	}
+	assert.Equal(t, uint64(1000), hash.EstimateBlockHeight(observed.Add(-time.Second)))
+	assert.Equal(t, uint64(1020), hash.EstimateBlockHeight(observed.Add(10*time.Second)))
+	assert.Equal(t, uint64(130), hash.RemainingBlocks(observed.Add(10*time.Second)))
+	assert.Equal(t, uint64(0), hash.RemainingBlocks(observed.Add(time.Minute+15*time.Second)))
+}
+
+// fakeChain serves block heights and hashes to a block hash monitor.
+type fakeChain struct {
+	lock      sync.Mutex
+	height    uint64
+	blockhash solana.Hash
+	hashErr   bool // fail getLatestBlockhash
+}
+
+func (f *fakeChain) handle(method string, _ []json.RawMessage) interface{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	switch method {
+	case "getBlockHeight":
+		return f.height
+	case "getLatestBlockhash":
+		if f.hashErr {
+			return nil
+		}
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": f.height},
+			"value": map[string]interface{}{
+				"blockhash":            f.blockhash.String(),
+				"lastValidBlockHeight": f.height + 150,
+			},
+		}
+	}
+	return nil
+}
+
+func (f *fakeChain) set(height uint64, blockhash solana.Hash, hashErr bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.height, f.blockhash, f.hashErr = height, blockhash, hashErr
+}
+
+func newTestBlockHashMonitor(t *testing.T, chain *fakeChain, now *time.Time) *BlockHashMonitor {
+	monitor := &BlockHashMonitor{
+		client:      newFakeRPC(t, chain.handle),
+		now:         func() time.Time { return *now },
+		Log:         zap.NewNop(),
+		MinValidFor: 30,
+	}
+	require.NoError(t, monitor.tick(context.Background()))
+	return monitor
+}
+
+func TestBlockHashMonitor_Tick(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	chain := &fakeChain{height: 1000, blockhash: solana.Hash{1}}
+	monitor := newTestBlockHashMonitor(t, chain, &now)
+	assert.Equal(t, blockTime, monitor.Latest().BlockTime)
+
+	// Blocks slower than the target shift the estimate.
+	now = now.Add(10 * time.Second)
+	chain.set(1010, solana.Hash{2}, false)
+	require.NoError(t, monitor.tick(ctx))
+	latest := monitor.Latest()
+	assert.Equal(t, solana.Hash{2}, latest.Blockhash)
+	assert.Equal(t, uint64(1160), latest.LastValidBlockHeight)
+	assert.Equal(t, 520*time.Millisecond, latest.BlockTime, "0.2 * 1s + 0.8 * 400ms")
+
+	// Failing to fetch a hash still corrects the height.
+	now = now.Add(10 * time.Second)
+	chain.set(1030, solana.Hash{3}, true)
+	assert.Error(t, monitor.tick(ctx))
+	latest = monitor.Latest()
+	assert.Equal(t, solana.Hash{2}, latest.Blockhash)
+	assert.Equal(t, uint64(1030), latest.BlockHeight)
+	assert.Equal(t, now, latest.Observed)
+	assert.Equal(t, uint64(130), latest.RemainingBlocks(now))
+}
+
+func TestBlockHashMonitor_HoldBack(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	chain := &fakeChain{height: 1000, blockhash: solana.Hash{1}}
+	monitor := newTestBlockHashMonitor(t, chain, &now)
+	hash, err := monitor.GetRecentBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, solana.Hash{1}, hash)
+
+	// The hash is not refreshed while the chain moves on.
+	chain.set(1121, solana.Hash{2}, true)
+	now = now.Add(time.Minute)
+	assert.Error(t, monitor.tick(ctx))
+	_, err = monitor.GetRecentBlockHash()
+	assert.ErrorIs(t, err, ErrBlockHashExpiring, "29 blocks left")
+
+	// The scheduler holds updates back instead of sending them.
+	buffer := NewBuffer()
+	buffer.PushUpdate(newTestUpdate(solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), 100))
+	sched := NewScheduler(buffer, monitor, nil, nil)
+	sched.Sender = senderFunc(func(context.Context, *solana.Transaction) (solana.Signature, error) {
+		return solana.Signature{}, errors.New("must not send")
+	})
+	sched.tick(ctx, &ws.SlotsUpdatesResult{Slot: 101})
+	assert.Len(t, buffer.Flush(0), 1, "update still buffered")
+
+	// Usable again after a fresh hash.
+	chain.set(1122, solana.Hash{3}, false)
+	require.NoError(t, monitor.tick(ctx))
+	hash, err = monitor.GetRecentBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, solana.Hash{3}, hash)
+}
+
+type senderFunc func(context.Context, *solana.Transaction) (solana.Signature, error)
+
+func (f senderFunc) Send(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	return f(ctx, tx)
+}
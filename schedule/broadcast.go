@@ -0,0 +1,175 @@
+package schedule
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"go.uber.org/zap"
+)
+
+// Endpoint is a named transaction sender, e.g. an RPC provider.
+type Endpoint struct {
+	Name   string
+	Sender Sender
+}
+
+// EndpointNames returns names of endpoint URLs fit for logs and metrics.
+//
+// Names are the host and path, without user info and query.
+// Path segments that look like API keys and the query are replaced by a short hash,
+// so that endpoints on the same host get distinct names without revealing credentials.
+// Repeated URLs get numbered.
+func EndpointNames(rawURLs []string) []string {
+	names := make([]string, len(rawURLs))
+	seen := make(map[string]int)
+	for i, rawURL := range rawURLs {
+		name := endpointName(rawURL)
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name = fmt.Sprintf("%s#%d", name, n)
+		}
+		names[i] = name
+	}
+	return names
+}
+
+func endpointName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return redact(rawURL)
+	}
+	name := u.Host
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "" {
+			continue
+		}
+		if looksLikeKey(segment) {
+			segment = redact(segment)
+		}
+		name += "/" + segment
+	}
+	if u.RawQuery != "" {
+		name += "?" + redact(u.RawQuery)
+	}
+	return name
+}
+
+// looksLikeKey returns whether a path segment might be a credential, i.e. long and containing digits.
+func looksLikeKey(segment string) bool {
+	return len(segment) >= 16 && strings.ContainsAny(segment, "0123456789")
+}
+
+// redact replaces a secret by a short hash.
+func redact(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "*" + hex.EncodeToString(sum[:4])
+}
+
+// BroadcastSender sends each transaction to multiple endpoints in parallel.
+//
+// By default, every transaction goes to all endpoints.
+// With hedging, it goes to the first HedgeCount endpoints,
+// and to the next ones only if none succeeded within HedgeDelay.
+//
+// Sends continue in the background after the first success,
+// so every endpoint gets the transaction and reports metrics.
+type BroadcastSender struct {
+	Log        *zap.Logger
+	HedgeCount int           // endpoints to send to at once, zero for all
+	HedgeDelay time.Duration // wait before sending to more endpoints, zero to wait for errors only
+	Timeout    time.Duration // max time per endpoint send
+
+	endpoints []Endpoint
+}
+
+func NewBroadcastSender(endpoints []Endpoint) *BroadcastSender {
+	return &BroadcastSender{
+		Log:       zap.NewNop(),
+		Timeout:   3 * time.Second,
+		endpoints: endpoints,
+	}
+}
+
+type broadcastResult struct {
+	endpoint string
+	sig      solana.Signature
+	err      error
+}
+
+func (b *BroadcastSender) Send(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	if len(b.endpoints) == 0 {
+		return solana.Signature{}, fmt.Errorf("no send endpoints")
+	}
+	groupSize := b.HedgeCount
+	if groupSize <= 0 || groupSize > len(b.endpoints) {
+		groupSize = len(b.endpoints)
+	}
+
+	// Buffered so that sends finishing after we returned never block.
+	results := make(chan broadcastResult, len(b.endpoints))
+	var launched int
+	var hedge <-chan time.Time
+	launch := func() {
+		end := launched + groupSize
+		if end > len(b.endpoints) {
+			end = len(b.endpoints)
+		}
+		for _, endpoint := range b.endpoints[launched:end] {
+			go func(endpoint Endpoint) {
+				results <- b.sendTo(endpoint, tx)
+			}(endpoint)
+		}
+		launched = end
+		hedge = nil
+		if launched < len(b.endpoints) && b.HedgeDelay > 0 {
+			hedge = time.After(b.HedgeDelay)
+		}
+	}
+	launch()
+
+	var errs []string
+	for received := 0; received < launched; {
+		select {
+		case <-ctx.Done():
+			return solana.Signature{}, ctx.Err()
+		case <-hedge:
+			b.Log.Debug("Hedging transaction", zap.Stringer("signature", tx.Signatures[0]))
+			launch()
+		case res := <-results:
+			received++
+			if res.err == nil {
+				return res.sig, nil
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s", res.endpoint, res.err))
+			if received == launched && launched < len(b.endpoints) {
+				launch()
+			}
+		}
+	}
+	return solana.Signature{}, fmt.Errorf("all endpoints failed: %s", strings.Join(errs, "; "))
+}
+
+// sendTo sends a transaction to a single endpoint, detached from the caller's context.
+func (b *BroadcastSender) sendTo(endpoint Endpoint, tx *solana.Transaction) broadcastResult {
+	ctx, cancel := context.WithTimeout(context.Background(), b.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	sig, err := endpoint.Sender.Send(ctx, tx)
+	metricEndpointLatency.WithLabelValues(endpoint.Name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		b.Log.Debug("Failed to send transaction to endpoint",
+			zap.String("endpoint", endpoint.Name),
+			zap.Error(err))
+		metricEndpointSends.WithLabelValues(endpoint.Name, "error").Inc()
+	} else {
+		metricEndpointSends.WithLabelValues(endpoint.Name, "success").Inc()
+	}
+	return broadcastResult{endpoint: endpoint.Name, sig: sig, err: err}
+}
@@ -10,20 +10,63 @@ import (
 
 // Buffer collects price update instructions.
 type Buffer struct {
-	Log *zap.Logger
+	Log       *zap.Logger
+	Watchdog  *Watchdog  // optional
+	Heartbeat *Heartbeat // optional
 
-	lock    sync.Mutex
-	updates map[solana.PublicKey]*pyth.Instruction
+	lock         sync.Mutex
+	updates      map[updateKey]*pyth.Instruction
+	latest       map[updateKey]*pyth.Instruction // last update pushed, even if already flushed
+	resubmits    map[updateKey]int               // resubmissions since last fresh quote
+	repeated     map[updateKey]bool              // pending update repeats an earlier quote
+	paused       bool
+	pausedPrices map[solana.PublicKey]bool
+}
+
+// updateKey identifies a pending update. Each publisher key has its own quote per price.
+type updateKey struct {
+	publisher solana.PublicKey
+	price     solana.PublicKey
+}
+
+// BufferStatus is a snapshot of the buffer state.
+type BufferStatus struct {
+	Pending      []solana.PublicKey `json:"pending"`
+	Paused       bool               `json:"paused"`
+	PausedPrices []solana.PublicKey `json:"paused_prices"`
 }
 
 func NewBuffer() *Buffer {
 	return &Buffer{
-		Log:     zap.NewNop(),
-		updates: make(map[solana.PublicKey]*pyth.Instruction),
+		Log:          zap.NewNop(),
+		updates:      make(map[updateKey]*pyth.Instruction),
+		latest:       make(map[updateKey]*pyth.Instruction),
+		resubmits:    make(map[updateKey]int),
+		repeated:     make(map[updateKey]bool),
+		pausedPrices: make(map[solana.PublicKey]bool),
 	}
 }
 
+// PushUpdate queues a price update instruction,
+// replacing any pending update for the same price and publisher.
 func (b *Buffer) PushUpdate(ins *pyth.Instruction) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.pushUpdate(ins, true)
+}
+
+// PushUpdates queues multiple price update instructions at once.
+//
+// Either all updates get picked up by the same Flush call, or none of them.
+func (b *Buffer) PushUpdates(insns []*pyth.Instruction) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, ins := range insns {
+		b.pushUpdate(ins, true)
+	}
+}
+
+func (b *Buffer) pushUpdate(ins *pyth.Instruction, fresh bool) {
 	_, ok := ins.Payload.(*pyth.CommandUpdPrice)
 	if !ok {
 		return
@@ -33,43 +76,177 @@ func (b *Buffer) PushUpdate(ins *pyth.Instruction) {
 		return
 	}
 
-	b.lock.Lock()
-	defer b.lock.Unlock()
-
 	publishAcc := accs[0].PublicKey
 	priceAcc := accs[1].PublicKey
-	if _, ok := b.updates[priceAcc]; ok {
+	if b.paused || b.pausedPrices[priceAcc] {
+		metricUpdatesDropped.
+			WithLabelValues(publishAcc.String(), priceAcc.String(), "paused").
+			Inc()
+		return
+	}
+	key := updateKey{publisher: publishAcc, price: priceAcc}
+	if _, ok := b.updates[key]; ok {
 		metricUpdatesDropped.
 			WithLabelValues(publishAcc.String(), priceAcc.String(), "replaced").
 			Inc()
 	}
-	b.updates[priceAcc] = ins
+	b.updates[key] = ins
+	b.latest[key] = ins
+	b.repeated[key] = !fresh
+	if fresh {
+		delete(b.resubmits, key)
+	}
+	if fresh && b.Watchdog != nil {
+		b.Watchdog.observe(ins)
+	}
+	if fresh && b.Heartbeat != nil {
+		b.Heartbeat.observe(ins)
+	}
+}
+
+// resubmit queues an update again with a new pub slot, if it is still the latest quote
+// and was resubmitted fewer than maxAttempts times since the last fresh quote.
+// Returns whether the update was queued.
+func (b *Buffer) resubmit(ins *pyth.Instruction, slot uint64, maxAttempts int) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	accs := ins.Accounts()
+	key := updateKey{publisher: accs[0].PublicKey, price: accs[1].PublicKey}
+	if b.latest[key] != ins || b.resubmits[key] >= maxAttempts {
+		return false
+	}
+	if _, pending := b.updates[key]; pending {
+		return false
+	}
+	cmd := *ins.Payload.(*pyth.CommandUpdPrice)
+	cmd.PubSlot = slot
+	retry := pyth.NewInstructionBuilder(ins.ProgramID()).
+		UpdPriceNoFailOnError(key.publisher, key.price, cmd)
+	b.pushUpdate(retry, false)
+	if b.latest[key] != retry {
+		return false // paused
+	}
+	b.resubmits[key]++
+	return true
+}
+
+// pushRepeated queues updates generated by the watchdog or heartbeat without counting them as fresh quotes.
+func (b *Buffer) pushRepeated(insns []*pyth.Instruction) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, ins := range insns {
+		b.pushUpdate(ins, false)
+	}
 }
 
-// Flush removes all queued instructions and places them into an unsigned transaction.
+// PauseAll drops all pending updates and rejects new ones until ResumeAll is called.
+func (b *Buffer) PauseAll() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.paused = true
+	b.clear()
+}
+
+// ResumeAll lifts the global pause and all pauses of individual price accounts.
+func (b *Buffer) ResumeAll() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.paused = false
+	b.pausedPrices = make(map[solana.PublicKey]bool)
+}
+
+// Pause drops pending updates of the given price account and rejects new ones until resumed.
+func (b *Buffer) Pause(price solana.PublicKey) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.pausedPrices[price] = true
+	for key := range b.updates {
+		if key.price == price {
+			delete(b.updates, key)
+		}
+	}
+	for key := range b.latest {
+		if key.price == price {
+			delete(b.latest, key)
+		}
+	}
+}
+
+// Resume lifts the pause of the given price account.
+func (b *Buffer) Resume(price solana.PublicKey) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.pausedPrices, price)
+}
+
+// Clear drops all pending updates and returns how many were dropped.
+func (b *Buffer) Clear() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.clear()
+}
+
+func (b *Buffer) clear() int {
+	n := len(b.updates)
+	b.updates = make(map[updateKey]*pyth.Instruction)
+	b.latest = make(map[updateKey]*pyth.Instruction)
+	return n
+}
+
+// Status returns a snapshot of the buffer state.
+func (b *Buffer) Status() BufferStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	status := BufferStatus{
+		Pending:      make([]solana.PublicKey, 0, len(b.updates)),
+		Paused:       b.paused,
+		PausedPrices: make([]solana.PublicKey, 0, len(b.pausedPrices)),
+	}
+	for key := range b.updates {
+		status.Pending = append(status.Pending, key.price)
+	}
+	for price := range b.pausedPrices {
+		status.PausedPrices = append(status.PausedPrices, price)
+	}
+	return status
+}
+
+// Flush removes all queued instructions and returns them.
 // Returns nil if the buffer is empty.
 //
 // Updates created earlier than the given minSlot will be removed.
-func (b *Buffer) Flush(minSlot uint64) *solana.TransactionBuilder {
+func (b *Buffer) Flush(minSlot uint64) []*pyth.Instruction {
+	return b.FlushDue(minSlot, nil)
+}
+
+// FlushDue is like Flush, but keeps fresh updates queued for which due returns false.
+//
+// Repeated quotes (heartbeats, staleness reports, resubmissions) and updates
+// created earlier than minSlot are always removed. A nil due func flushes everything.
+func (b *Buffer) FlushDue(minSlot uint64, due func(*pyth.Instruction) bool) []*pyth.Instruction {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
-	// TODO(richard): Will fail if payload exceeds MTU, split into multiple txs
-	builder := solana.NewTransactionBuilder()
-	var updates uint
-	for price, insn := range b.updates {
-		delete(b.updates, price)
-		if b.appendUpdateToBuilder(builder, insn, minSlot) {
-			updates++
+	var insns []*pyth.Instruction
+	for key, insn := range b.updates {
+		if due != nil && !b.repeated[key] && !isStale(insn, minSlot) && !due(insn) {
+			continue
+		}
+		delete(b.updates, key)
+		if b.checkUpdate(insn, minSlot) {
+			insns = append(insns, insn)
 		}
 	}
-	if updates == 0 {
-		return nil
-	}
-	return builder
+	return insns
+}
+
+func isStale(insn *pyth.Instruction, minSlot uint64) bool {
+	update, ok := insn.Payload.(*pyth.CommandUpdPrice)
+	return ok && update.PubSlot < minSlot
 }
 
-func (b *Buffer) appendUpdateToBuilder(builder *solana.TransactionBuilder, insn *pyth.Instruction, minSlot uint64) bool {
+func (b *Buffer) checkUpdate(insn *pyth.Instruction, minSlot uint64) bool {
 	update, ok := insn.Payload.(*pyth.CommandUpdPrice)
 	if !ok {
 		return false
@@ -90,6 +267,5 @@ func (b *Buffer) appendUpdateToBuilder(builder *solana.TransactionBuilder, insn
 	metricUpdatesSent.
 		WithLabelValues(publishAccStr, priceAccStr).
 		Inc()
-	builder.AddInstruction(insn)
 	return true
 }
@@ -0,0 +1,175 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+)
+
+// Cadence decides when fresh price updates get published.
+//
+// Updates that are not due stay buffered, and may get replaced by newer quotes
+// or dropped once older than the scheduler's staleness window.
+type Cadence interface {
+	// Due returns whether to publish an update now, given the last published one (nil if none).
+	Due(now time.Time, slot uint64, update *pyth.CommandUpdPrice, last *PublishedUpdate) bool
+}
+
+// PublishedUpdate is a price update as it was published.
+type PublishedUpdate struct {
+	Time   time.Time
+	Slot   uint64
+	Update pyth.CommandUpdPrice
+}
+
+// EverySlot publishes every update in the next slot.
+type EverySlot struct{}
+
+func (EverySlot) Due(time.Time, uint64, *pyth.CommandUpdPrice, *PublishedUpdate) bool {
+	return true
+}
+
+// EveryNSlots publishes at most once every N slots.
+type EveryNSlots uint64
+
+func (n EveryNSlots) Due(_ time.Time, slot uint64, _ *pyth.CommandUpdPrice, last *PublishedUpdate) bool {
+	return last == nil || slot >= last.Slot+uint64(n)
+}
+
+// Interval publishes at most once per wall-clock interval.
+type Interval time.Duration
+
+func (d Interval) Due(now time.Time, _ uint64, _ *pyth.CommandUpdPrice, last *PublishedUpdate) bool {
+	return last == nil || now.Sub(last.Time) >= time.Duration(d)
+}
+
+// Threshold publishes when the price moved by more than the given ratio or the status changed.
+type Threshold float64
+
+func (t Threshold) Due(_ time.Time, _ uint64, update *pyth.CommandUpdPrice, last *PublishedUpdate) bool {
+	if last == nil || update.Status != last.Update.Status {
+		return true
+	}
+	prev := float64(last.Update.Price)
+	diff := float64(update.Price) - prev
+	if diff < 0 {
+		diff = -diff
+	}
+	if prev < 0 {
+		prev = -prev
+	}
+	return diff > float64(t)*prev
+}
+
+// ParseCadence parses a cadence spec:
+//
+//	slot              every slot
+//	slots:<n>         every n slots
+//	interval:<dur>    every wall-clock interval, e.g. "interval:2s"
+//	threshold:<ratio> on price moves beyond ratio, e.g. "threshold:0.001"
+func ParseCadence(spec string) (Cadence, error) {
+	kind, arg, hasArg := spec, "", false
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		kind, arg, hasArg = spec[:i], spec[i+1:], true
+	}
+	switch {
+	case kind == "slot" && !hasArg:
+		return EverySlot{}, nil
+	case kind == "slots" && hasArg:
+		n, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid slot count %q", arg)
+		}
+		return EveryNSlots(n), nil
+	case kind == "interval" && hasArg:
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval %q", arg)
+		}
+		return Interval(d), nil
+	case kind == "threshold" && hasArg:
+		ratio, err := strconv.ParseFloat(arg, 64)
+		if err != nil || ratio < 0 {
+			return nil, fmt.Errorf("invalid threshold %q", arg)
+		}
+		return Threshold(ratio), nil
+	default:
+		return nil, fmt.Errorf("unknown cadence %q", spec)
+	}
+}
+
+// CadencePolicy assigns cadences to price accounts and remembers what was published.
+//
+// Only fresh quotes count as published, once their transaction was sent.
+// Repeated quotes (heartbeats, staleness reports, resubmissions) are ignored.
+type CadencePolicy struct {
+	Default  Cadence
+	Accounts map[solana.PublicKey]Cadence // overrides by price account
+
+	lock    sync.Mutex
+	last    map[updateKey]*PublishedUpdate
+	pending map[updateKey]pendingUpdate // flushed, but not sent yet
+}
+
+type pendingUpdate struct {
+	ins       *pyth.Instruction
+	published PublishedUpdate
+}
+
+func NewCadencePolicy(def Cadence) *CadencePolicy {
+	return &CadencePolicy{
+		Default:  def,
+		Accounts: make(map[solana.PublicKey]Cadence),
+		last:     make(map[updateKey]*PublishedUpdate),
+		pending:  make(map[updateKey]pendingUpdate),
+	}
+}
+
+// due returns whether a fresh update should be published in the given slot.
+//
+// Updates found due are remembered until published reports them sent.
+func (c *CadencePolicy) due(now time.Time, slot uint64, ins *pyth.Instruction) bool {
+	update, ok := ins.Payload.(*pyth.CommandUpdPrice)
+	if !ok {
+		return true
+	}
+	accs := ins.Accounts()
+	key := updateKey{publisher: accs[0].PublicKey, price: accs[1].PublicKey}
+	cadence, ok := c.Accounts[key.price]
+	if !ok {
+		cadence = c.Default
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !cadence.Due(now, slot, update, c.last[key]) {
+		return false
+	}
+	c.pending[key] = pendingUpdate{
+		ins:       ins,
+		published: PublishedUpdate{Time: now, Slot: slot, Update: *update},
+	}
+	return true
+}
+
+// published records updates of a transaction that was sent.
+//
+// Updates not previously found due are ignored.
+func (c *CadencePolicy) published(insns []*pyth.Instruction) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, ins := range insns {
+		accs := ins.Accounts()
+		key := updateKey{publisher: accs[0].PublicKey, price: accs[1].PublicKey}
+		pending, ok := c.pending[key]
+		if !ok || pending.ins != ins {
+			continue
+		}
+		delete(c.pending, key)
+		c.last[key] = &pending.published
+	}
+}
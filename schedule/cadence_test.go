@@ -0,0 +1,90 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+func TestParseCadence(t *testing.T) {
+	for spec, want := range map[string]Cadence{
+		"slot":            EverySlot{},
+		"slots:4":         EveryNSlots(4),
+		"interval:1500ms": Interval(1500 * time.Millisecond),
+		"threshold:0.01":  Threshold(0.01),
+	} {
+		got, err := ParseCadence(spec)
+		require.NoError(t, err, spec)
+		assert.Equal(t, want, got, spec)
+	}
+	for _, spec := range []string{"", "slot:1", "slots", "slots:0", "interval:-1s", "threshold:x", "hourly"} {
+		_, err := ParseCadence(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCadence_Due(t *testing.T) {
+	now := time.Unix(1000, 0)
+	last := &PublishedUpdate{
+		Time:   now,
+		Slot:   100,
+		Update: pyth.CommandUpdPrice{Status: 1, Price: 10_000},
+	}
+	update := &pyth.CommandUpdPrice{Status: 1, Price: 10_050}
+
+	assert.True(t, EveryNSlots(4).Due(now, 100, update, nil))
+	assert.False(t, EveryNSlots(4).Due(now, 103, update, last))
+	assert.True(t, EveryNSlots(4).Due(now, 104, update, last))
+
+	assert.False(t, Interval(time.Second).Due(now.Add(999*time.Millisecond), 0, update, last))
+	assert.True(t, Interval(time.Second).Due(now.Add(time.Second), 0, update, last))
+
+	assert.False(t, Threshold(0.01).Due(now, 0, update, last))
+	assert.True(t, Threshold(0.001).Due(now, 0, update, last))
+	assert.True(t, Threshold(0.01).Due(now, 0, &pyth.CommandUpdPrice{Status: 0, Price: 10_000}, last))
+}
+
+func TestCadencePolicy_Published(t *testing.T) {
+	policy := NewCadencePolicy(EveryNSlots(4))
+	buffer := NewBuffer()
+	publisher, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	key := updateKey{publisher: publisher, price: price}
+	now := time.Unix(1000, 0)
+	flush := func(slot uint64) []*pyth.Instruction {
+		return buffer.FlushDue(0, func(ins *pyth.Instruction) bool {
+			return policy.due(now, slot, ins)
+		})
+	}
+
+	// Not recorded until sent, so a failed send does not delay the next quote.
+	buffer.PushUpdate(newTestUpdate(publisher, price, 100))
+	dropped := flush(100)
+	require.Len(t, dropped, 1)
+	assert.Nil(t, policy.last[key])
+	buffer.PushUpdate(newTestUpdate(publisher, price, 101))
+	sent := flush(101)
+	require.Len(t, sent, 1)
+	policy.published(sent)
+	require.NotNil(t, policy.last[key])
+	assert.Equal(t, uint64(101), policy.last[key].Slot)
+
+	// Late reports of older flushes are ignored.
+	policy.published(dropped)
+	assert.Equal(t, uint64(101), policy.last[key].Slot)
+
+	// Fresh quotes wait for the cadence.
+	buffer.PushUpdate(newTestUpdate(publisher, price, 102))
+	assert.Empty(t, flush(102))
+	assert.Len(t, flush(105), 1)
+
+	// Repeated quotes are always flushed, but never count as published.
+	buffer.pushRepeated([]*pyth.Instruction{newTestUpdate(publisher, price, 106)})
+	repeated := flush(106)
+	require.Len(t, repeated, 1)
+	policy.published(repeated)
+	assert.Equal(t, uint64(101), policy.last[key].Slot)
+}
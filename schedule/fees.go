@@ -0,0 +1,195 @@
+package schedule
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"go.blockdaemon.com/pyth"
+	"go.uber.org/zap"
+)
+
+// ComputeBudgetProgramID is the address of the native compute budget program.
+var ComputeBudgetProgramID = solana.MustPublicKeyFromBase58("ComputeBudget111111111111111111111111111111")
+
+// Compute budget instruction discriminators.
+const (
+	computeBudgetSetUnitLimit = 2
+	computeBudgetSetUnitPrice = 3
+)
+
+// NewSetComputeUnitLimitInstruction requests a compute unit limit for the transaction.
+func NewSetComputeUnitLimitInstruction(units uint32) solana.Instruction {
+	data := make([]byte, 5)
+	data[0] = computeBudgetSetUnitLimit
+	binary.LittleEndian.PutUint32(data[1:], units)
+	return solana.NewInstruction(ComputeBudgetProgramID, solana.AccountMetaSlice{}, data)
+}
+
+// NewSetComputeUnitPriceInstruction sets the priority fee in micro-lamports per compute unit.
+func NewSetComputeUnitPriceInstruction(microLamports uint64) solana.Instruction {
+	data := make([]byte, 9)
+	data[0] = computeBudgetSetUnitPrice
+	binary.LittleEndian.PutUint64(data[1:], microLamports)
+	return solana.NewInstruction(ComputeBudgetProgramID, solana.AccountMetaSlice{}, data)
+}
+
+// priorityFee returns the priority fee in lamports requested by a transaction's compute budget instructions.
+func priorityFee(tx *solana.Transaction) uint64 {
+	var units, price uint64
+	for _, ins := range tx.Message.Instructions {
+		if !tx.Message.AccountKeys[ins.ProgramIDIndex].Equals(ComputeBudgetProgramID) {
+			continue
+		}
+		switch {
+		case len(ins.Data) == 5 && ins.Data[0] == computeBudgetSetUnitLimit:
+			units = uint64(binary.LittleEndian.Uint32(ins.Data[1:]))
+		case len(ins.Data) == 9 && ins.Data[0] == computeBudgetSetUnitPrice:
+			price = binary.LittleEndian.Uint64(ins.Data[1:])
+		}
+	}
+	// Round up, like the runtime does.
+	return (units*price + 999_999) / 1_000_000
+}
+
+// PriorityFees sets the compute budget of price update transactions.
+//
+// The compute unit price is either fixed, or estimated from recent prioritization fees
+// paid for writing to the Pyth program and price accounts.
+type PriorityFees struct {
+	Log            *zap.Logger
+	UnitsPerUpdate uint32        // compute units requested per price update
+	UnitsOverhead  uint32        // compute units requested per transaction, for compute budget and nonce instructions
+	Percentile     float64       // percentile of recent fees to pay when estimating (0-100)
+	Floor          uint64        // min estimated price in micro-lamports
+	Ceiling        uint64        // max estimated price in micro-lamports, zero for no limit
+	Interval       time.Duration // time between estimates
+
+	rpc      *rpc.Client
+	program  solana.PublicKey
+	price    uint64 // atomic
+	lock     sync.Mutex
+	accounts map[solana.PublicKey]bool
+}
+
+// NewPriorityFees creates a compute budget policy with a fixed unit price in micro-lamports.
+//
+// Call Run to estimate the unit price instead, starting from the given one.
+func NewPriorityFees(rpc *rpc.Client, program solana.PublicKey, unitPrice uint64) *PriorityFees {
+	metricPriorityFeePrice.Set(float64(unitPrice))
+	return &PriorityFees{
+		Log:            zap.NewNop(),
+		UnitsPerUpdate: 30_000,
+		UnitsOverhead:  1_000,
+		Percentile:     50,
+		Interval:       10 * time.Second,
+		rpc:            rpc,
+		program:        program,
+		price:          unitPrice,
+		accounts:       make(map[solana.PublicKey]bool),
+	}
+}
+
+// Price returns the current compute unit price in micro-lamports.
+func (p *PriorityFees) Price() uint64 {
+	return atomic.LoadUint64(&p.price)
+}
+
+// Instructions returns the compute budget instructions for a transaction with the given number of updates.
+//
+// The unit limit covers the updates, plus an overhead for the instructions preceding them.
+func (p *PriorityFees) Instructions(numUpdates int) []solana.Instruction {
+	return []solana.Instruction{
+		NewSetComputeUnitLimitInstruction(p.UnitsOverhead + p.UnitsPerUpdate*uint32(numUpdates)),
+		NewSetComputeUnitPriceInstruction(p.Price()),
+	}
+}
+
+// Observe remembers the price accounts of updates to estimate fees over.
+func (p *PriorityFees) Observe(updates []*pyth.Instruction) {
+	// getRecentPrioritizationFees accepts up to 128 accounts, one is the program.
+	const maxAccounts = 127
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, ins := range updates {
+		if len(p.accounts) >= maxAccounts {
+			return
+		}
+		p.accounts[ins.Accounts()[1].PublicKey] = true
+	}
+}
+
+// Run periodically estimates the compute unit price until the context is cancelled.
+func (p *PriorityFees) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.estimate(ctx); err != nil && ctx.Err() == nil {
+			p.Log.Warn("Failed to estimate priority fee", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *PriorityFees) estimate(ctx context.Context) error {
+	p.lock.Lock()
+	accounts := make([]solana.PublicKey, 0, len(p.accounts)+1)
+	accounts = append(accounts, p.program)
+	for acc := range p.accounts {
+		accounts = append(accounts, acc)
+	}
+	p.lock.Unlock()
+
+	var res []struct {
+		Slot              uint64 `json:"slot"`
+		PrioritizationFee uint64 `json:"prioritizationFee"`
+	}
+	if err := p.rpc.RPCCallForInto(ctx, &res, "getRecentPrioritizationFees", []interface{}{accounts}); err != nil {
+		return err
+	}
+	fees := make([]uint64, len(res))
+	for i, r := range res {
+		fees[i] = r.PrioritizationFee
+	}
+
+	price := percentile(fees, p.Percentile)
+	if price < p.Floor {
+		price = p.Floor
+	}
+	if p.Ceiling != 0 && price > p.Ceiling {
+		price = p.Ceiling
+	}
+	atomic.StoreUint64(&p.price, price)
+	metricPriorityFeePrice.Set(float64(price))
+	p.Log.Debug("Estimated priority fee",
+		zap.Uint64("micro_lamports", price),
+		zap.Int("samples", len(fees)))
+	return nil
+}
+
+// percentile returns the nearest-rank percentile of the given values, zero if empty.
+func percentile(values []uint64, pct float64) uint64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]uint64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(pct / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
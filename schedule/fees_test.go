@@ -0,0 +1,102 @@
+package schedule
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []uint64{50, 10, 40, 20, 30}
+	cases := []struct {
+		pct  float64
+		want uint64
+	}{
+		{0, 10},
+		{20, 10},
+		{21, 20},
+		{50, 30},
+		{90, 50},
+		{100, 50},
+		{150, 50},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, percentile(values, c.pct), "p%v", c.pct)
+	}
+	assert.Equal(t, uint64(0), percentile(nil, 50))
+	assert.Equal(t, []uint64{50, 10, 40, 20, 30}, values, "input not reordered")
+}
+
+func TestPriorityFees_Instructions(t *testing.T) {
+	fees := NewPriorityFees(nil, pyth.Devnet.Program, 1_500)
+	fees.UnitsPerUpdate = 20_000
+	fees.UnitsOverhead = 600
+
+	insns := fees.Instructions(3)
+	require.Len(t, insns, 2)
+	for _, ins := range insns {
+		assert.Equal(t, ComputeBudgetProgramID, ins.ProgramID())
+		assert.Empty(t, ins.Accounts())
+	}
+	limit, err := insns[0].Data()
+	require.NoError(t, err)
+	assert.Equal(t, byte(computeBudgetSetUnitLimit), limit[0])
+	assert.Equal(t, uint32(60_600), binary.LittleEndian.Uint32(limit[1:]))
+	price, err := insns[1].Data()
+	require.NoError(t, err)
+	assert.Equal(t, byte(computeBudgetSetUnitPrice), price[0])
+	assert.Equal(t, uint64(1_500), binary.LittleEndian.Uint64(price[1:]))
+
+	// Fee paid by a transaction carrying the instructions.
+	publisher := solana.NewWallet().PublicKey()
+	tx, err := buildTx(fees.Instructions, newTestUpdates(publisher, 3), publisher, solana.Hash{1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(91), priorityFee(tx), "60600 units at 1500 micro-lamports, rounded up")
+}
+
+func TestPriorityFees_Estimate(t *testing.T) {
+	var lock sync.Mutex
+	var samples []uint64
+	setSamples := func(fees ...uint64) {
+		lock.Lock()
+		defer lock.Unlock()
+		samples = fees
+	}
+	client := newFakeRPC(t, func(method string, params []json.RawMessage) interface{} {
+		assert.Equal(t, "getRecentPrioritizationFees", method)
+		var accounts []string
+		assert.NoError(t, json.Unmarshal(params[0], &accounts))
+		assert.Equal(t, pyth.Devnet.Program.String(), accounts[0])
+		lock.Lock()
+		defer lock.Unlock()
+		res := make([]map[string]uint64, len(samples))
+		for i, fee := range samples {
+			res[i] = map[string]uint64{"slot": uint64(100 + i), "prioritizationFee": fee}
+		}
+		return res
+	})
+	fees := NewPriorityFees(client, pyth.Devnet.Program, 0)
+	fees.Percentile = 75
+	fees.Floor = 100
+	fees.Ceiling = 10_000
+	ctx := context.Background()
+
+	setSamples(0, 200, 400, 600, 800, 1_000, 1_200, 1_400)
+	require.NoError(t, fees.estimate(ctx))
+	assert.Equal(t, uint64(1_000), fees.Price())
+
+	setSamples(0, 0, 0, 50)
+	require.NoError(t, fees.estimate(ctx))
+	assert.Equal(t, uint64(100), fees.Price(), "floor")
+
+	setSamples(50_000, 60_000)
+	require.NoError(t, fees.estimate(ctx))
+	assert.Equal(t, uint64(10_000), fees.Price(), "ceiling")
+}
@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+	"go.uber.org/zap"
+)
+
+// Heartbeat republishes the last quote of a price when no fresh quote arrived for a while.
+//
+// Attach it to a Buffer to observe quotes.
+type Heartbeat struct {
+	Log      *zap.Logger
+	Interval uint64                    // slots without a fresh quote before republishing
+	MaxHold  uint64                    // slots after the last fresh quote until republishing stops
+	Accounts map[solana.PublicKey]bool // price accounts to republish, nil for all
+
+	buffer *Buffer
+	lock   sync.Mutex
+	prices map[updateKey]*heartbeatState
+}
+
+type heartbeatState struct {
+	last      *pyth.Instruction // last fresh quote
+	freshSlot uint64            // publish slot of last fresh quote
+	sentSlot  uint64            // publish slot of last quote or republish
+}
+
+// NewHeartbeat creates a new unstarted heartbeat pushing into the given buffer.
+func NewHeartbeat(buffer *Buffer, interval, maxHold uint64) *Heartbeat {
+	return &Heartbeat{
+		Log:      zap.NewNop(),
+		Interval: interval,
+		MaxHold:  maxHold,
+		buffer:   buffer,
+		prices:   make(map[updateKey]*heartbeatState),
+	}
+}
+
+// Run republishes quotes on slot updates until the context is cancelled.
+func (h *Heartbeat) Run(ctx context.Context, slots *SlotMonitor) {
+	cancel := slots.Subscribe(h.check)
+	defer cancel()
+	<-ctx.Done()
+}
+
+// observe records a fresh quote.
+func (h *Heartbeat) observe(ins *pyth.Instruction) {
+	update, ok := ins.Payload.(*pyth.CommandUpdPrice)
+	if !ok {
+		return
+	}
+	accs := ins.Accounts()
+	key := updateKey{publisher: accs[0].PublicKey, price: accs[1].PublicKey}
+	if h.Accounts != nil && !h.Accounts[key.price] {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.prices[key] = &heartbeatState{
+		last:      ins,
+		freshSlot: update.PubSlot,
+		sentSlot:  update.PubSlot,
+	}
+}
+
+// forget stops republishing the given prices until the next fresh quote.
+func (h *Heartbeat) forget(keys ...updateKey) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, key := range keys {
+		delete(h.prices, key)
+	}
+}
+
+// check republishes quotes that were not refreshed within the interval.
+func (h *Heartbeat) check(slot uint64) {
+	var insns []*pyth.Instruction
+	h.lock.Lock()
+	for key, state := range h.prices {
+		if slot >= state.freshSlot+h.MaxHold {
+			h.Log.Debug("Stopped republishing price, hold time exceeded",
+				zap.Stringer("price", key.price),
+				zap.Uint64("fresh_slot", state.freshSlot))
+			delete(h.prices, key)
+			continue
+		}
+		if slot < state.sentSlot+h.Interval {
+			continue
+		}
+		state.sentSlot = slot
+
+		cmd := *state.last.Payload.(*pyth.CommandUpdPrice)
+		cmd.PubSlot = slot
+		insns = append(insns, pyth.NewInstructionBuilder(state.last.ProgramID()).
+			UpdPriceNoFailOnError(key.publisher, key.price, cmd))
+		metricHeartbeats.WithLabelValues(key.publisher.String(), key.price.String()).Inc()
+	}
+	h.lock.Unlock()
+
+	if len(insns) > 0 {
+		h.buffer.pushRepeated(insns)
+	}
+}
@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+func TestHeartbeat(t *testing.T) {
+	buffer := NewBuffer()
+	heartbeat := NewHeartbeat(buffer, 10, 30)
+	buffer.Heartbeat = heartbeat
+	publisher, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	buffer.PushUpdate(newTestUpdate(publisher, price, 100))
+	require.Len(t, buffer.Flush(0), 1)
+
+	// Quote is republished every interval at the current slot.
+	heartbeat.check(109)
+	assert.Empty(t, buffer.Flush(0))
+	heartbeat.check(110)
+	insns := buffer.Flush(0)
+	require.Len(t, insns, 1)
+	update := insns[0].Payload.(*pyth.CommandUpdPrice)
+	assert.Equal(t, uint32(pyth.PriceStatusTrading), update.Status)
+	assert.Equal(t, int64(100), update.Price)
+	assert.Equal(t, uint64(110), update.PubSlot)
+	heartbeat.check(119)
+	assert.Empty(t, buffer.Flush(0))
+	heartbeat.check(120)
+	assert.Len(t, buffer.Flush(0), 1)
+
+	// Republishing stops MaxHold slots after the last fresh quote.
+	heartbeat.check(130)
+	assert.Empty(t, buffer.Flush(0))
+	heartbeat.check(140)
+	assert.Empty(t, buffer.Flush(0))
+
+	// A fresh quote starts over.
+	buffer.PushUpdate(newTestUpdate(publisher, price, 200))
+	require.Len(t, buffer.Flush(0), 1)
+	heartbeat.check(210)
+	assert.Len(t, buffer.Flush(0), 1)
+}
+
+func TestHeartbeat_Accounts(t *testing.T) {
+	buffer := NewBuffer()
+	heartbeat := NewHeartbeat(buffer, 10, 30)
+	buffer.Heartbeat = heartbeat
+	publisher := solana.NewWallet().PublicKey()
+	included, excluded := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	heartbeat.Accounts = map[solana.PublicKey]bool{included: true}
+
+	buffer.PushUpdates([]*pyth.Instruction{
+		newTestUpdate(publisher, included, 100),
+		newTestUpdate(publisher, excluded, 100),
+	})
+	require.Len(t, buffer.Flush(0), 2)
+
+	heartbeat.check(110)
+	insns := buffer.Flush(0)
+	require.Len(t, insns, 1)
+	assert.Equal(t, included, insns[0].Accounts()[1].PublicKey)
+}
+
+func TestHeartbeat_Watchdog(t *testing.T) {
+	buffer := NewBuffer()
+	heartbeat := NewHeartbeat(buffer, 5, 150)
+	watchdog := NewWatchdog(buffer, 20)
+	buffer.Heartbeat = heartbeat
+	buffer.Watchdog = watchdog
+	publisher, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	buffer.PushUpdate(newTestUpdate(publisher, price, 100))
+	require.Len(t, buffer.Flush(0), 1)
+
+	// Heartbeats don't keep the price fresh.
+	for slot := uint64(101); slot < 120; slot++ {
+		heartbeat.check(slot)
+		watchdog.check(slot)
+	}
+	assert.Len(t, buffer.Flush(0), 1)
+
+	// Once stale, only unknown status is published, even within the hold time.
+	for slot := uint64(120); slot < 200; slot++ {
+		watchdog.check(slot)
+		heartbeat.check(slot)
+	}
+	insns := buffer.Flush(0)
+	require.Len(t, insns, 1)
+	assert.Equal(t, uint32(pyth.PriceStatusUnknown), insns[0].Payload.(*pyth.CommandUpdPrice).Status)
+
+	// A fresh quote resumes heartbeats.
+	buffer.PushUpdate(newTestUpdate(publisher, price, 300))
+	require.Len(t, buffer.Flush(0), 1)
+	heartbeat.check(305)
+	insns = buffer.Flush(0)
+	require.Len(t, insns, 1)
+	assert.Equal(t, uint32(pyth.PriceStatusTrading), insns[0].Payload.(*pyth.CommandUpdPrice).Status)
+}
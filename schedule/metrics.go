@@ -12,12 +12,48 @@ var (
 		Name:      "blockhash_updates_total",
 		Help:      "Number of block hash updates received",
 	})
+	metricBlockhashAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "blockhash_age_seconds",
+		Help:      "Time since the latest block hash was fetched",
+	})
+	metricBlockhashRemaining = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "blockhash_remaining_blocks",
+		Help:      "Estimated blocks until the latest block hash expires",
+	})
+	metricBlockhashStaleTicks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "blockhash_stale_ticks_total",
+		Help:      "Number of slots in which sending was held back due to an expiring block hash",
+	})
 	metricSlotUpdates = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: "pythian",
 		Subsystem: "solana",
 		Name:      "slot_updates_total",
 		Help:      "Number of slot updates received",
 	})
+	metricSlotSource = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "slot_source",
+		Help:      "Active slot update source per endpoint (1 if active)",
+	}, []string{"endpoint", "source"})
+	metricSlotEndpointLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "slot_endpoint_lag",
+		Help:      "Slots an endpoint is behind the highest slot seen",
+	}, []string{"endpoint"})
+	metricSlotEndpointDemoted = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "slot_endpoint_demoted",
+		Help:      "Whether an endpoint is demoted for lagging (1 if demoted)",
+	}, []string{"endpoint"})
 	metricTxsSent = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "pythian",
 		Subsystem: "solana",
@@ -36,4 +72,84 @@ var (
 		Name:      "price_updates_sent_total",
 		Help:      "Number of Pyth price updates sent",
 	}, []string{"pyth_publisher", "pyth_price"})
+	metricStalePrices = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pythian",
+		Subsystem: "watchdog",
+		Name:      "price_stale",
+		Help:      "Whether a price is marked stale due to missing quotes (1 if stale)",
+	}, []string{"pyth_publisher", "pyth_price"})
+	metricStaleEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "watchdog",
+		Name:      "stale_events_total",
+		Help:      "Number of times a price went stale and got marked unknown",
+	}, []string{"pyth_publisher", "pyth_price"})
+	metricTxStatus = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "transaction_status_total",
+		Help:      "Number of Pyth transaction status changes observed",
+	}, []string{"status"})
+	metricHeartbeats = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "heartbeat",
+		Name:      "republished_total",
+		Help:      "Number of quotes republished by the heartbeat",
+	}, []string{"pyth_publisher", "pyth_price"})
+	metricPriorityFeePrice = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "priority_fee_micro_lamports",
+		Help:      "Current compute unit price of Pyth transactions, in micro-lamports",
+	})
+	metricPriorityFeesPaid = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "priority_fees_lamports_total",
+		Help:      "Priority fees requested by sent Pyth transactions, in lamports",
+	}, []string{"pyth_publisher"})
+	metricTxLandingSlots = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "transaction_landing_slots",
+		Help:      "Slots between sending a Pyth transaction and it getting processed",
+		Buckets:   []float64{0, 1, 2, 3, 4, 6, 8, 12, 16, 32, 64, 150},
+	})
+	metricUpdatesResubmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "price_updates_resubmitted_total",
+		Help:      "Number of Pyth price updates resubmitted after their transaction expired",
+	}, []string{"pyth_publisher", "pyth_price"})
+	metricEndpointSends = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "endpoint_sends_total",
+		Help:      "Number of transactions sent per endpoint by result",
+	}, []string{"endpoint", "result"})
+	metricEndpointLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "endpoint_send_seconds",
+		Help:      "Time taken to send a transaction per endpoint",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"endpoint"})
+	metricTPUPackets = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "tpu_packets_total",
+		Help:      "Number of transactions sent directly to leader TPUs by result",
+	}, []string{"result"})
+	metricNonceUpdates = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "nonce_updates_total",
+		Help:      "Number of durable nonce values observed",
+	})
+	metricNonceFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "solana",
+		Name:      "nonce_fallbacks_total",
+		Help:      "Number of transactions using a recent block hash because no durable nonce was available",
+	})
 )
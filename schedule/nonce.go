@@ -0,0 +1,236 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+	"go.uber.org/zap"
+)
+
+// NonceAccountSize is the size of a system program nonce account.
+const NonceAccountSize = 80
+
+// Nonce is a durable nonce value usable in place of a recent block hash.
+type Nonce struct {
+	Account   solana.PublicKey
+	Authority solana.PublicKey
+	Value     solana.Hash
+}
+
+// AdvanceInstruction returns the instruction consuming the nonce,
+// which must come first in the transaction.
+func (n Nonce) AdvanceInstruction() solana.Instruction {
+	return system.NewAdvanceNonceAccountInstruction(n.Account, solana.SysVarRecentBlockHashesPubkey, n.Authority).Build()
+}
+
+// withNonce prepends the advance instruction of a nonce to a transaction prefix, which may be nil.
+func withNonce(nonce Nonce, prefix func(int) []solana.Instruction) func(int) []solana.Instruction {
+	return func(numUpdates int) []solana.Instruction {
+		insns := []solana.Instruction{nonce.AdvanceInstruction()}
+		if prefix != nil {
+			insns = append(insns, prefix(numUpdates)...)
+		}
+		return insns
+	}
+}
+
+// NonceMonitor follows the values of durable nonce accounts via account subscriptions.
+//
+// Each nonce value is handed out once, as the transaction using it advances the account.
+// A value is handed out again only if its transaction was not sent (see Release),
+// or if the account did not change within ExpirySlots after handing it out.
+// At most one transaction with the same value lands.
+type NonceMonitor struct {
+	Log          *zap.Logger
+	WebSocketURL string
+	ExpirySlots  uint64 // slots after handing out a value until its transaction is considered expired
+
+	lock     sync.Mutex
+	accounts []solana.PublicKey
+	nonces   map[solana.PublicKey]*nonceState
+}
+
+type nonceState struct {
+	Nonce
+	usedSlot uint64 // zero if unused
+}
+
+// NewNonceMonitor creates a new unstarted monitor for the given nonce accounts.
+//
+// It also fetches the current nonce values during the lifetime of the given context.
+func NewNonceMonitor(ctx context.Context, client *rpc.Client, wsURL string, accounts []solana.PublicKey) (*NonceMonitor, error) {
+	monitor := &NonceMonitor{
+		Log:          zap.NewNop(),
+		WebSocketURL: wsURL,
+		ExpirySlots:  150,
+		accounts:     accounts,
+		nonces:       make(map[solana.PublicKey]*nonceState, len(accounts)),
+	}
+	for _, account := range accounts {
+		res, err := client.GetAccountInfoWithOpts(ctx, account, &rpc.GetAccountInfoOpts{Commitment: rpc.CommitmentConfirmed})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get nonce account %s: %w", account, err)
+		}
+		if err := monitor.update(account, res.Value); err != nil {
+			return nil, err
+		}
+	}
+	return monitor, nil
+}
+
+// parseNonceAccount decodes an initialized nonce account.
+func parseNonceAccount(data []byte) (*system.NonceAccount, error) {
+	if len(data) != NonceAccountSize {
+		return nil, fmt.Errorf("not a nonce account, size %d", len(data))
+	}
+	var acc system.NonceAccount
+	if err := bin.NewBinDecoder(data).Decode(&acc); err != nil {
+		return nil, err
+	}
+	if acc.State != 1 {
+		return nil, errors.New("nonce account not initialized")
+	}
+	return &acc, nil
+}
+
+func (n *NonceMonitor) update(account solana.PublicKey, info *rpc.Account) error {
+	if info == nil || info.Data == nil {
+		return fmt.Errorf("nonce account %s not found", account)
+	}
+	if !info.Owner.Equals(solana.SystemProgramID) {
+		return fmt.Errorf("nonce account %s not owned by system program", account)
+	}
+	acc, err := parseNonceAccount(info.Data.GetBinary())
+	if err != nil {
+		return fmt.Errorf("invalid nonce account %s: %w", account, err)
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	state, ok := n.nonces[account]
+	if ok && state.Value == solana.Hash(acc.Nonce) {
+		return nil
+	}
+	n.nonces[account] = &nonceState{Nonce: Nonce{
+		Account:   account,
+		Authority: acc.AuthorizedPubkey,
+		Value:     solana.Hash(acc.Nonce),
+	}}
+	n.Log.Debug("Updated nonce",
+		zap.Stringer("account", account),
+		zap.Stringer("nonce", solana.Hash(acc.Nonce)))
+	metricNonceUpdates.Inc()
+	return nil
+}
+
+// Has returns whether any nonce account is controlled by the given authority.
+func (n *NonceMonitor) Has(authority solana.PublicKey) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, state := range n.nonces {
+		if state.Authority == authority {
+			return true
+		}
+	}
+	return false
+}
+
+// Acquire hands out an unused nonce controlled by the given authority, for a transaction sent at the given slot.
+func (n *NonceMonitor) Acquire(authority solana.PublicKey, slot uint64) (Nonce, bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, account := range n.accounts {
+		state, ok := n.nonces[account]
+		if !ok || state.Authority != authority {
+			continue
+		}
+		if state.usedSlot != 0 && slot <= state.usedSlot+n.ExpirySlots {
+			continue
+		}
+		if state.usedSlot != 0 {
+			n.Log.Debug("Reusing nonce of expired transaction", zap.Stringer("account", account))
+		}
+		state.usedSlot = slot
+		return state.Nonce, true
+	}
+	return Nonce{}, false
+}
+
+// Release makes a nonce available again after failing to send its transaction.
+//
+// Does nothing if the nonce account advanced in the meantime.
+func (n *NonceMonitor) Release(nonce Nonce) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if state, ok := n.nonces[nonce.Account]; ok && state.Value == nonce.Value {
+		state.usedSlot = 0
+	}
+}
+
+// Advanced returns whether the nonce account moved on from the given nonce value.
+//
+// Transactions using an advanced nonce can no longer land.
+func (n *NonceMonitor) Advanced(nonce Nonce) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	state, ok := n.nonces[nonce.Account]
+	return !ok || state.Value != nonce.Value
+}
+
+// Run follows nonce accounts until the context is cancelled.
+func (n *NonceMonitor) Run(ctx context.Context) error {
+	return Reconnect(ctx, n.Log, n.runConn)
+}
+
+func (n *NonceMonitor) runConn(ctx context.Context) error {
+	client, err := ws.Connect(ctx, n.WebSocketURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Make sure client cannot outlive context.
+	go func() {
+		defer client.Close()
+		<-ctx.Done()
+	}()
+
+	errs := make(chan error, len(n.accounts))
+	for _, account := range n.accounts {
+		sub, err := client.AccountSubscribe(account, rpc.CommitmentProcessed)
+		if err != nil {
+			return err
+		}
+		go func(account solana.PublicKey, sub *ws.AccountSubscription) {
+			defer sub.Unsubscribe()
+			for {
+				res, err := sub.Recv()
+				if err != nil {
+					errs <- err
+					return
+				} else if res == nil {
+					errs <- net.ErrClosed
+					return
+				}
+				if err := n.update(account, &res.Value.Account); err != nil {
+					n.Log.Warn("Ignoring nonce account update", zap.Error(err))
+				}
+			}
+		}(account, sub)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errs:
+		return err
+	}
+}
@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newNonceAccount returns the JSON-RPC representation of an initialized nonce account.
+func newNonceAccount(authority solana.PublicKey, value solana.Hash) map[string]interface{} {
+	data := make([]byte, NonceAccountSize)
+	binary.LittleEndian.PutUint32(data[4:8], 1) // initialized
+	copy(data[8:40], authority[:])
+	copy(data[40:72], value[:])
+	return map[string]interface{}{
+		"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+		"owner":      solana.SystemProgramID.String(),
+		"lamports":   1_447_680,
+		"executable": false,
+		"rentEpoch":  0,
+	}
+}
+
+func TestNonceMonitor(t *testing.T) {
+	authority := solana.NewWallet().PublicKey()
+	accounts := []solana.PublicKey{solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()}
+	values := map[string]solana.Hash{
+		accounts[0].String(): {1},
+		accounts[1].String(): {2},
+	}
+	client := newFakeRPC(t, func(method string, params []json.RawMessage) interface{} {
+		assert.Equal(t, "getAccountInfo", method)
+		var account string
+		assert.NoError(t, json.Unmarshal(params[0], &account))
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": 100},
+			"value":   newNonceAccount(authority, values[account]),
+		}
+	})
+	nonces, err := NewNonceMonitor(context.Background(), client, "", accounts)
+	require.NoError(t, err)
+	nonces.ExpirySlots = 10
+	assert.True(t, nonces.Has(authority))
+	assert.False(t, nonces.Has(solana.NewWallet().PublicKey()))
+
+	// Each value is handed out once.
+	first, ok := nonces.Acquire(authority, 100)
+	require.True(t, ok)
+	second, ok := nonces.Acquire(authority, 100)
+	require.True(t, ok)
+	assert.ElementsMatch(t, []solana.Hash{{1}, {2}}, []solana.Hash{first.Value, second.Value})
+	_, ok = nonces.Acquire(authority, 110)
+	assert.False(t, ok, "not expired yet")
+	_, ok = nonces.Acquire(solana.NewWallet().PublicKey(), 100)
+	assert.False(t, ok, "other authority")
+
+	// Released after failing to send.
+	nonces.Release(first)
+	nonce, ok := nonces.Acquire(authority, 105)
+	require.True(t, ok)
+	assert.Equal(t, first, nonce)
+
+	// Handed out again once the account advanced.
+	var advanced rpc.Account
+	buf, err := json.Marshal(newNonceAccount(authority, solana.Hash{3}))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(buf, &advanced))
+	require.NoError(t, nonces.update(first.Account, &advanced))
+	nonce, ok = nonces.Acquire(authority, 106)
+	require.True(t, ok)
+	assert.Equal(t, Nonce{Account: first.Account, Authority: authority, Value: solana.Hash{3}}, nonce)
+
+	// Releasing a stale value does nothing.
+	nonces.Release(first)
+	_, ok = nonces.Acquire(authority, 106)
+	assert.False(t, ok)
+
+	// Handed out again once its transaction expired.
+	nonce, ok = nonces.Acquire(authority, 111)
+	require.True(t, ok)
+	assert.Equal(t, second, nonce)
+}
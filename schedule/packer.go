@@ -0,0 +1,237 @@
+package schedule
+
+import (
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+)
+
+// MaxTransactionSize is the max size of a serialized transaction,
+// the IPv6 minimum MTU minus IP and UDP headers.
+const MaxTransactionSize = 1232
+
+// Packer splits price updates into as many transactions as needed to fit the size limit.
+type Packer struct {
+	MaxSize  int                      // max serialized transaction size
+	Priority map[solana.PublicKey]int // price accounts with higher priority go into earlier transactions
+}
+
+// Batch is an unsigned transaction and the price updates it carries.
+type Batch struct {
+	Tx      *solana.Transaction
+	Updates []*pyth.Instruction
+
+	nonce                *Nonce // durable nonce used in place of a recent block hash, if any
+	lastValidBlockHeight uint64 // block height the recent block hash expires after, zero with a nonce
+}
+
+func NewPacker() *Packer {
+	return &Packer{MaxSize: MaxTransactionSize}
+}
+
+// Pack builds unsigned transactions paid for by feePayer.
+//
+// Every transaction starts with the instructions returned by prefix, if not nil.
+// The prefix must have the same size regardless of the number of updates.
+// Updates too large to fit any transaction are dropped.
+func (p *Packer) Pack(
+	prefix func(numUpdates int) []solana.Instruction,
+	updates []*pyth.Instruction,
+	feePayer solana.PublicKey,
+	blockhash solana.Hash,
+) ([]Batch, error) {
+	updates = p.sort(updates)
+
+	// Size of a transaction carrying only the prefix.
+	empty := newTxSize(feePayer)
+	if prefix != nil {
+		for _, ins := range prefix(1) {
+			d, err := empty.delta(ins)
+			if err != nil {
+				return nil, err
+			}
+			empty.apply(d)
+		}
+	}
+
+	var groups [][]*pyth.Instruction
+	var current []*pyth.Instruction
+	size := empty.clone()
+	for _, ins := range updates {
+		d, err := size.delta(ins)
+		if err != nil {
+			return nil, err
+		}
+		if size.size(d) > p.MaxSize && len(current) > 0 {
+			// Start a new transaction.
+			groups = append(groups, current)
+			current, size = nil, empty.clone()
+			if d, err = size.delta(ins); err != nil {
+				return nil, err
+			}
+		}
+		if size.size(d) > p.MaxSize {
+			accs := ins.Accounts()
+			metricUpdatesDropped.
+				WithLabelValues(accs[0].PublicKey.String(), accs[1].PublicKey.String(), "too_large").
+				Inc()
+			continue
+		}
+		current = append(current, ins)
+		size.apply(d)
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+
+	batches := make([]Batch, 0, len(groups))
+	for _, group := range groups {
+		tx, err := buildTx(prefix, group, feePayer, blockhash)
+		if err != nil {
+			return batches, err
+		}
+		batches = append(batches, Batch{Tx: tx, Updates: group})
+	}
+	return batches, nil
+}
+
+// sort orders updates by descending priority, then by price account for determinism.
+func (p *Packer) sort(updates []*pyth.Instruction) []*pyth.Instruction {
+	sorted := make([]*pyth.Instruction, len(updates))
+	copy(sorted, updates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Accounts()[1].PublicKey, sorted[j].Accounts()[1].PublicKey
+		if pa, pb := p.Priority[a], p.Priority[b]; pa != pb {
+			return pa > pb
+		}
+		return a.String() < b.String()
+	})
+	return sorted
+}
+
+// buildTx assembles an unsigned transaction.
+func buildTx(
+	prefix func(numUpdates int) []solana.Instruction,
+	updates []*pyth.Instruction,
+	feePayer solana.PublicKey,
+	blockhash solana.Hash,
+) (*solana.Transaction, error) {
+	builder := solana.NewTransactionBuilder()
+	if prefix != nil {
+		for _, ins := range prefix(len(updates)) {
+			builder.AddInstruction(ins)
+		}
+	}
+	for _, ins := range updates {
+		builder.AddInstruction(ins)
+	}
+	builder.SetFeePayer(feePayer)
+	builder.SetRecentBlockHash(blockhash)
+	return builder.Build()
+}
+
+// txSize tracks the serialized size of a signed legacy transaction as instructions get added.
+type txSize struct {
+	keys     map[solana.PublicKey]bool // account key => is signer
+	numSigs  int
+	numIns   int
+	insBytes int // compiled instructions, excluding the length prefix
+}
+
+// txDelta is the change in size caused by adding an instruction.
+type txDelta struct {
+	insLen  int                       // compiled instruction size
+	keys    map[solana.PublicKey]bool // keys added or promoted to signers
+	newKeys int
+	newSigs int
+}
+
+func newTxSize(feePayer solana.PublicKey) *txSize {
+	return &txSize{
+		keys:    map[solana.PublicKey]bool{feePayer: true},
+		numSigs: 1,
+	}
+}
+
+func (t *txSize) clone() *txSize {
+	keys := make(map[solana.PublicKey]bool, len(t.keys))
+	for key, signer := range t.keys {
+		keys[key] = signer
+	}
+	c := *t
+	c.keys = keys
+	return &c
+}
+
+// size returns the serialized size in bytes after applying d.
+func (t *txSize) size(d txDelta) int {
+	numSigs := t.numSigs + d.newSigs
+	numKeys := len(t.keys) + d.newKeys
+	numIns := t.numIns
+	if d.insLen > 0 {
+		numIns++
+	}
+	return compactU16Len(numSigs) + numSigs*64 + // signatures
+		3 + // message header
+		compactU16Len(numKeys) + numKeys*32 + // account keys
+		32 + // recent blockhash
+		compactU16Len(numIns) + t.insBytes + d.insLen
+}
+
+// delta computes the change in size of adding an instruction.
+func (t *txSize) delta(ins solana.Instruction) (txDelta, error) {
+	data, err := ins.Data()
+	if err != nil {
+		return txDelta{}, err
+	}
+	accs := ins.Accounts()
+	d := txDelta{
+		insLen: 1 + // program ID index
+			compactU16Len(len(accs)) + len(accs) +
+			compactU16Len(len(data)) + len(data),
+		keys: make(map[solana.PublicKey]bool),
+	}
+	note := func(key solana.PublicKey, signer bool) {
+		wasSigner, known := d.keys[key]
+		if !known {
+			wasSigner, known = t.keys[key]
+			if !known {
+				d.newKeys++
+			}
+		}
+		if !known || (signer && !wasSigner) {
+			d.keys[key] = signer || wasSigner
+			if signer && !wasSigner {
+				d.newSigs++
+			}
+		}
+	}
+	note(ins.ProgramID(), false)
+	for _, acc := range accs {
+		note(acc.PublicKey, acc.IsSigner)
+	}
+	return d, nil
+}
+
+// apply adds an instruction given its delta.
+func (t *txSize) apply(d txDelta) {
+	for key, signer := range d.keys {
+		t.keys[key] = signer
+	}
+	t.numSigs += d.newSigs
+	t.numIns++
+	t.insBytes += d.insLen
+}
+
+// compactU16Len returns the encoded size of a compact-u16 integer.
+func compactU16Len(n int) int {
+	switch {
+	case n < 1<<7:
+		return 1
+	case n < 1<<14:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,118 @@
+package schedule
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+// wireSize returns the serialized size of a transaction once signed.
+func wireSize(t *testing.T, tx *solana.Transaction) int {
+	msg, err := tx.Message.MarshalBinary()
+	require.NoError(t, err)
+	numSigs := int(tx.Message.Header.NumRequiredSignatures)
+	return compactU16Len(numSigs) + numSigs*64 + len(msg)
+}
+
+func newTestUpdates(publisher solana.PublicKey, n int) []*pyth.Instruction {
+	updates := make([]*pyth.Instruction, n)
+	for i := range updates {
+		updates[i] = newTestUpdate(publisher, solana.NewWallet().PublicKey(), 100)
+	}
+	return updates
+}
+
+func batchSizes(batches []Batch) []int {
+	sizes := make([]int, len(batches))
+	for i, batch := range batches {
+		sizes[i] = len(batch.Updates)
+	}
+	return sizes
+}
+
+func TestPacker_Pack_Empty(t *testing.T) {
+	batches, err := NewPacker().Pack(nil, nil, solana.NewWallet().PublicKey(), solana.Hash{1})
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+}
+
+func TestPacker_Pack_ExactFit(t *testing.T) {
+	publisher := solana.NewWallet().PublicKey()
+	updates := newTestUpdates(publisher, 2)
+	tx, err := buildTx(nil, updates, publisher, solana.Hash{1})
+	require.NoError(t, err)
+	size := wireSize(t, tx)
+
+	packer := NewPacker()
+	packer.MaxSize = size
+	batches, err := packer.Pack(nil, updates, publisher, solana.Hash{1})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, batchSizes(batches))
+	assert.Equal(t, size, wireSize(t, batches[0].Tx))
+
+	packer.MaxSize = size - 1
+	batches, err = packer.Pack(nil, updates, publisher, solana.Hash{1})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1}, batchSizes(batches))
+}
+
+func TestPacker_Pack_Overflow(t *testing.T) {
+	publisher := solana.NewWallet().PublicKey()
+	updates := newTestUpdates(publisher, 3)
+	tx, err := buildTx(nil, updates[:2], publisher, solana.Hash{1})
+	require.NoError(t, err)
+
+	packer := NewPacker()
+	packer.MaxSize = wireSize(t, tx)
+	batches, err := packer.Pack(nil, updates, publisher, solana.Hash{1})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, batchSizes(batches))
+	for _, batch := range batches {
+		assert.LessOrEqual(t, wireSize(t, batch.Tx), packer.MaxSize)
+	}
+}
+
+func TestPacker_Pack_Prefix(t *testing.T) {
+	publisher := solana.NewWallet().PublicKey()
+	nonce := Nonce{Account: solana.NewWallet().PublicKey(), Authority: publisher, Value: solana.Hash{2}}
+	var counts []int
+	prefix := withNonce(nonce, func(numUpdates int) []solana.Instruction {
+		counts = append(counts, numUpdates)
+		return nil
+	})
+
+	packer := NewPacker()
+	updates := newTestUpdates(publisher, 30)
+	batches, err := packer.Pack(prefix, updates, publisher, nonce.Value)
+	require.NoError(t, err)
+	require.Greater(t, len(batches), 1)
+
+	var total int
+	for _, batch := range batches {
+		total += len(batch.Updates)
+		assert.LessOrEqual(t, wireSize(t, batch.Tx), packer.MaxSize)
+		require.Len(t, batch.Tx.Message.Instructions, len(batch.Updates)+1)
+		program, err := batch.Tx.ResolveProgramIDIndex(batch.Tx.Message.Instructions[0].ProgramIDIndex)
+		require.NoError(t, err)
+		assert.Equal(t, solana.SystemProgramID, program, "nonce advance comes first")
+	}
+	assert.Equal(t, len(updates), total)
+	assert.Equal(t, batchSizes(batches), counts[1:], "prefix built for each batch")
+
+	// The first batch is full: one more update would not have fit.
+	tx, err := buildTx(prefix, append(batches[0].Updates[:len(batches[0].Updates):len(batches[0].Updates)], batches[1].Updates[0]), publisher, nonce.Value)
+	require.NoError(t, err)
+	assert.Greater(t, wireSize(t, tx), packer.MaxSize)
+}
+
+func TestPacker_Pack_TooLarge(t *testing.T) {
+	publisher := solana.NewWallet().PublicKey()
+	packer := NewPacker()
+	packer.MaxSize = 200
+	batches, err := packer.Pack(nil, newTestUpdates(publisher, 2), publisher, solana.Hash{1})
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+}
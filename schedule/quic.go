@@ -0,0 +1,153 @@
+package schedule
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// TPUQUICPortOffset is the distance of a node's QUIC TPU port from its advertised UDP TPU port.
+const TPUQUICPortOffset = 6
+
+// tpuALPN is the application protocol spoken by QUIC TPUs.
+const tpuALPN = "solana-tpu"
+
+// QUICTransport sends transactions to QUIC TPUs, each on its own unidirectional stream.
+//
+// Connections are reused across transactions until they go idle.
+// The client certificate is self-signed by an ephemeral key, so it carries no stake.
+type QUICTransport struct {
+	PortOffset int // QUIC port relative to the UDP TPU port
+
+	transport *quic.Transport
+	tlsConf   *tls.Config
+	quicConf  *quic.Config
+
+	lock  sync.Mutex
+	conns map[string]quic.Connection
+}
+
+// NewQUICTransport opens a UDP socket on an ephemeral port to carry QUIC connections.
+func NewQUICTransport() (*QUICTransport, error) {
+	cert, err := newTPUCertificate()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &QUICTransport{
+		PortOffset: TPUQUICPortOffset,
+		transport:  &quic.Transport{Conn: conn},
+		tlsConf: &tls.Config{
+			Certificates:       []tls.Certificate{cert},
+			NextProtos:         []string{tpuALPN},
+			InsecureSkipVerify: true, // validators use self-signed certificates
+		},
+		quicConf: &quic.Config{
+			HandshakeIdleTimeout: 2 * time.Second,
+			MaxIdleTimeout:       10 * time.Second,
+		},
+		conns: make(map[string]quic.Connection),
+	}, nil
+}
+
+func (q *QUICTransport) Send(ctx context.Context, tpu *net.UDPAddr, packet []byte) error {
+	addr := &net.UDPAddr{IP: tpu.IP, Port: tpu.Port + q.PortOffset, Zone: tpu.Zone}
+	conn, err := q.connect(ctx, addr)
+	if err != nil {
+		return err
+	}
+	stream, err := conn.OpenUniStreamSync(ctx)
+	if err != nil {
+		q.drop(addr.String(), conn)
+		return err
+	}
+	if _, err := stream.Write(packet); err != nil {
+		stream.CancelWrite(0)
+		return err
+	}
+	return stream.Close()
+}
+
+// connect returns an open connection to the given QUIC TPU, dialing a new one if needed.
+func (q *QUICTransport) connect(ctx context.Context, addr *net.UDPAddr) (quic.Connection, error) {
+	key := addr.String()
+	q.lock.Lock()
+	conn, ok := q.conns[key]
+	if ok && conn.Context().Err() != nil {
+		delete(q.conns, key)
+		ok = false
+	}
+	q.lock.Unlock()
+	if ok {
+		return conn, nil
+	}
+
+	// Dial without holding the lock, so sends to other leaders don't wait.
+	conn, err := q.transport.Dial(ctx, addr, q.tlsConf, q.quicConf)
+	if err != nil {
+		return nil, err
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if existing, ok := q.conns[key]; ok && existing.Context().Err() == nil {
+		_ = conn.CloseWithError(0, "")
+		return existing, nil
+	}
+	q.conns[key] = conn
+	return conn, nil
+}
+
+func (q *QUICTransport) drop(key string, conn quic.Connection) {
+	_ = conn.CloseWithError(0, "")
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.conns[key] == conn {
+		delete(q.conns, key)
+	}
+}
+
+// Close closes all connections and the underlying socket.
+func (q *QUICTransport) Close() error {
+	q.lock.Lock()
+	for key, conn := range q.conns {
+		_ = conn.CloseWithError(0, "")
+		delete(q.conns, key)
+	}
+	q.lock.Unlock()
+	if err := q.transport.Close(); err != nil {
+		return err
+	}
+	return q.transport.Conn.Close()
+}
+
+// newTPUCertificate creates a self-signed client certificate, as validators expect one.
+func newTPUCertificate() (tls.Certificate, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Solana node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		IPAddresses:  []net.IP{net.IPv4zero},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}, nil
+}
@@ -0,0 +1,34 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.uber.org/zap"
+)
+
+// reconnectInterval is the time to wait before reconnecting a stream.
+var reconnectInterval = 3 * time.Second
+
+// errReconnect makes a stream reconnect without logging an error.
+var errReconnect = errors.New("reconnect")
+
+// Reconnect runs a stream connection until the context is cancelled,
+// reconnecting after a short wait whenever it ends.
+//
+// Errors returned by conn get logged, conn returns nil to reconnect silently.
+func Reconnect(ctx context.Context, log *zap.Logger, conn func(context.Context) error) error {
+	return backoff.Retry(func() error {
+		err := conn(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return backoff.Permanent(ctxErr)
+		}
+		if err != nil {
+			log.Error("Stream failed, restarting", zap.Error(err))
+			return err
+		}
+		return errReconnect
+	}, backoff.WithContext(backoff.NewConstantBackOff(reconnectInterval), ctx))
+}
@@ -0,0 +1,35 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestReconnect(t *testing.T) {
+	defer func(interval time.Duration) { reconnectInterval = interval }(reconnectInterval)
+	reconnectInterval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int
+	err := Reconnect(ctx, zap.NewNop(), func(ctx context.Context) error {
+		calls++
+		switch calls {
+		case 1:
+			return errors.New("connection reset")
+		case 2:
+			return nil
+		default:
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 3, calls, "reconnects after errors and clean closes")
+}
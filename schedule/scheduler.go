@@ -3,35 +3,56 @@ package schedule
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/gagliardetto/solana-go/rpc/ws"
+	"go.blockdaemon.com/pyth"
 	"go.blockdaemon.com/pythian/signer"
 	"go.uber.org/zap"
 )
 
 // Scheduler buffers price updates and submits transactions.
 type Scheduler struct {
-	Log *zap.Logger
+	Log     *zap.Logger
+	Stats   *PublishStats // optional
+	Sender  Sender        // sends to RPC by default
+	Tracker *TxTracker    // optional
+	Packer  *Packer
+	Fees    *PriorityFees  // optional
+	Nonces  *NonceMonitor  // optional, replaces recent block hashes
+	Cadence *CadencePolicy // optional, publishes every slot by default
+
+	StaleSlots uint64 // drop updates older than this many slots
 
 	buffer    *Buffer
 	blockhash *BlockHashMonitor
 	signer    *signer.Signer
-	rpc       *rpc.Client
 	wg        sync.WaitGroup
+	lastSlot  uint64
+	inFlight  int64
+}
+
+// SchedulerStatus is a snapshot of the scheduler state.
+type SchedulerStatus struct {
+	LastSlot uint64 `json:"last_slot"`
+	InFlight int64  `json:"in_flight"`
 }
 
 // NewScheduler creates a new unstarted scheduler.
 func NewScheduler(buffer *Buffer, blockhash *BlockHashMonitor, signer *signer.Signer, rpc *rpc.Client) *Scheduler {
 	return &Scheduler{
-		Log: zap.NewNop(),
+		Log:    zap.NewNop(),
+		Sender: NewRPCSender(rpc),
+		Packer: NewPacker(),
+
+		StaleSlots: 32,
 
 		buffer:    buffer,
 		blockhash: blockhash,
 		signer:    signer,
-		rpc:       rpc,
 	}
 }
 
@@ -46,41 +67,196 @@ func (s *Scheduler) Run(ctx context.Context, updates <-chan *ws.SlotsUpdatesResu
 	}
 }
 
+// Status returns a snapshot of the scheduler state.
+func (s *Scheduler) Status() SchedulerStatus {
+	return SchedulerStatus{
+		LastSlot: atomic.LoadUint64(&s.lastSlot),
+		InFlight: atomic.LoadInt64(&s.inFlight),
+	}
+}
+
 func (s *Scheduler) tick(ctx context.Context, update *ws.SlotsUpdatesResult) {
-	// Assemble transaction.
-	builder := s.buffer.Flush(update.Slot - 32)
-	if builder == nil {
+	atomic.StoreUint64(&s.lastSlot, update.Slot)
+
+	// Hold updates back rather than paying for transactions that cannot land.
+	// Durable nonces don't need a recent block hash.
+	blockhash, err := s.blockhash.recent()
+	if err != nil {
+		metricBlockhashStaleTicks.Inc()
+		if s.Nonces == nil {
+			s.Log.Debug("Not sending", zap.Error(err))
+			return
+		}
+	}
+
+	var minSlot uint64
+	if update.Slot > s.StaleSlots {
+		minSlot = update.Slot - s.StaleSlots
+	}
+	var updates []*pyth.Instruction
+	if s.Cadence != nil {
+		now := time.Now()
+		updates = s.buffer.FlushDue(minSlot, func(ins *pyth.Instruction) bool {
+			return s.Cadence.due(now, update.Slot, ins)
+		})
+	} else {
+		updates = s.buffer.Flush(minSlot)
+	}
+	if len(updates) == 0 {
 		return
 	}
-	builder.SetFeePayer(s.signer.Pubkey())
-	builder.SetRecentBlockHash(s.blockhash.GetRecentBlockHash().Blockhash)
-	tx, err := builder.Build()
+
+	// Each publisher pays for its own transaction.
+	byPublisher := make(map[solana.PublicKey][]*pyth.Instruction)
+	var publishers []solana.PublicKey
+	for _, ins := range updates {
+		publisher := ins.Accounts()[0].PublicKey
+		if _, ok := byPublisher[publisher]; !ok {
+			publishers = append(publishers, publisher)
+		}
+		byPublisher[publisher] = append(byPublisher[publisher], ins)
+	}
+	for _, publisher := range publishers {
+		s.submit(ctx, publisher, byPublisher[publisher], blockhash, update.Slot)
+	}
+}
+
+// submit signs and sends transactions with the given updates, paid for by the given publisher.
+//
+// Updates are split across as many transactions as needed, which are sent concurrently.
+//
+// The recent block hash is nil if it is about to expire, leaving only durable nonces to send with.
+func (s *Scheduler) submit(ctx context.Context, publisher solana.PublicKey, updates []*pyth.Instruction, blockhash *BlockHash, slot uint64) {
+	// Assemble transactions.
+	var prefix func(int) []solana.Instruction
+	if s.Fees != nil {
+		s.Fees.Observe(updates)
+		prefix = s.Fees.Instructions
+	}
+	var nonce Nonce
+	var useNonce bool
+	if s.Nonces != nil {
+		nonce, useNonce = s.Nonces.Acquire(publisher, slot)
+		if !useNonce && s.Nonces.Has(publisher) {
+			s.Log.Debug("No durable nonce available, using recent block hash", zap.Stringer("publisher", publisher))
+			metricNonceFallbacks.Inc()
+		}
+	}
+	if useNonce {
+		batches, err := s.Packer.Pack(withNonce(nonce, prefix), updates, publisher, nonce.Value)
+		if err != nil {
+			s.Log.Error("Failed to build transaction", zap.Error(err))
+			s.Nonces.Release(nonce)
+			return
+		}
+		if len(batches) == 0 {
+			s.Nonces.Release(nonce)
+			return
+		}
+		batches[0].nonce = &nonce
+		s.submitNonced(ctx, publisher, batches, prefix, blockhash, slot)
+		return
+	}
+	if blockhash == nil {
+		s.Log.Warn("No durable nonce or fresh block hash, dropping updates", zap.Stringer("publisher", publisher))
+		return
+	}
+	batches, err := s.Packer.Pack(prefix, updates, publisher, blockhash.Blockhash)
 	if err != nil {
 		s.Log.Error("Failed to build transaction", zap.Error(err))
 		return
 	}
+	for i := range batches {
+		batches[i].lastValidBlockHeight = blockhash.LastValidBlockHeight
+	}
+	s.signAndSend(ctx, publisher, batches, slot)
+}
 
-	// Sign transaction.
-	if err := s.signer.SignPriceUpdate(tx); err != nil {
-		s.Log.Error("Failed to sign transaction", zap.Error(err))
+// submitNonced sends transactions packed with a durable nonce.
+//
+// Every transaction advances its nonce, so all but the first get rebuilt with other nonces.
+// Transactions without a nonce left fall back to the recent block hash, if not nil.
+func (s *Scheduler) submitNonced(
+	ctx context.Context,
+	publisher solana.PublicKey,
+	batches []Batch,
+	prefix func(int) []solana.Instruction,
+	blockhash *BlockHash,
+	slot uint64,
+) {
+	for i := 1; i < len(batches); i++ {
+		var tx *solana.Transaction
+		var err error
+		nonce, ok := s.Nonces.Acquire(publisher, slot)
+		if ok {
+			tx, err = buildTx(withNonce(nonce, prefix), batches[i].Updates, publisher, nonce.Value)
+			if err != nil {
+				s.Nonces.Release(nonce)
+			}
+		} else if blockhash == nil {
+			err = ErrBlockHashExpiring
+		} else {
+			metricNonceFallbacks.Inc()
+			tx, err = buildTx(prefix, batches[i].Updates, publisher, blockhash.Blockhash)
+			batches[i].lastValidBlockHeight = blockhash.LastValidBlockHeight
+		}
+		if err != nil {
+			s.Log.Error("Failed to build transaction", zap.Error(err))
+			batches = batches[:i]
+			break
+		}
+		batches[i].Tx = tx
+		if ok {
+			batches[i].nonce = &nonce
+		}
 	}
+	s.signAndSend(ctx, publisher, batches, slot)
+}
 
-	s.Log.Debug("Submitting price update",
-		zap.Stringer("publisher", &tx.Message.AccountKeys[0]),
-		zap.Int("updates", len(tx.Message.Instructions)))
+// signAndSend signs and concurrently sends the given transactions.
+func (s *Scheduler) signAndSend(ctx context.Context, publisher solana.PublicKey, batches []Batch, slot uint64) {
+	for _, batch := range batches {
+		// Sign transaction.
+		if err := s.signer.SignPriceUpdate(batch.Tx); err != nil {
+			s.Log.Error("Failed to sign transaction",
+				zap.Stringer("publisher", publisher),
+				zap.Error(err))
+			s.releaseNonce(batch)
+			continue
+		}
 
-	s.wg.Add(1)
-	go s.sendTransaction(ctx, tx)
+		s.Log.Debug("Submitting price update",
+			zap.Stringer("publisher", publisher),
+			zap.Int("updates", len(batch.Updates)),
+			zap.Int("transactions", len(batches)))
+
+		s.wg.Add(1)
+		go s.sendTransaction(ctx, batch, slot)
+	}
 }
 
-func (s *Scheduler) sendTransaction(ctx context.Context, tx *solana.Transaction) {
+// releaseNonce makes the nonce of a transaction that was not sent available again.
+func (s *Scheduler) releaseNonce(batch Batch) {
+	if batch.nonce != nil {
+		s.Nonces.Release(*batch.nonce)
+	}
+}
+
+func (s *Scheduler) sendTransaction(ctx context.Context, batch Batch, slot uint64) {
+	tx, updates := batch.Tx, batch.Updates
 	defer s.wg.Done()
+	atomic.AddInt64(&s.inFlight, 1)
+	defer atomic.AddInt64(&s.inFlight, -1)
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	sig, err := s.rpc.SendTransactionWithOpts(ctx, tx, true, rpc.CommitmentProcessed)
+	sig, err := s.Sender.Send(ctx, tx)
 	if err != nil {
 		s.Log.Error("Failed to send transaction", zap.Error(err))
+		s.releaseNonce(batch)
+		if s.Tracker != nil {
+			s.Tracker.Failed(tx.Signatures[0], updates, slot, err)
+		}
 		return
 	}
 
@@ -90,4 +266,27 @@ func (s *Scheduler) sendTransaction(ctx context.Context, tx *solana.Transaction)
 	metricTxsSent.
 		WithLabelValues(tx.Message.AccountKeys[0].String()).
 		Inc()
+	if fee := priorityFee(tx); fee > 0 {
+		metricPriorityFeesPaid.
+			WithLabelValues(tx.Message.AccountKeys[0].String()).
+			Add(float64(fee))
+	}
+	if s.Cadence != nil {
+		s.Cadence.published(updates)
+	}
+	if s.Stats != nil {
+		s.Stats.RecordSubmission(updates, slot)
+	}
+	if s.Tracker != nil {
+		s.Tracker.Track(sig, batch, slot)
+	}
+}
+
+// updatePrices returns the price accounts of the given update instructions.
+func updatePrices(updates []*pyth.Instruction) []solana.PublicKey {
+	prices := make([]solana.PublicKey, len(updates))
+	for i, ins := range updates {
+		prices[i] = ins.Accounts()[1].PublicKey
+	}
+	return prices
 }
@@ -0,0 +1,132 @@
+package schedule
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"go.uber.org/zap"
+)
+
+// Sender submits signed transactions.
+type Sender interface {
+	Send(ctx context.Context, tx *solana.Transaction) (solana.Signature, error)
+}
+
+// RPCSender sends transactions to the cluster via RPC.
+type RPCSender struct {
+	rpc *rpc.Client
+}
+
+func NewRPCSender(rpc *rpc.Client) *RPCSender {
+	return &RPCSender{rpc: rpc}
+}
+
+func (r *RPCSender) Send(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	return r.rpc.SendTransactionWithOpts(ctx, tx, true, rpc.CommitmentProcessed)
+}
+
+// DiscardSender drops transactions without sending them.
+type DiscardSender struct{}
+
+func (DiscardSender) Send(_ context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	return tx.Signatures[0], nil
+}
+
+// SimulateSender simulates transactions via RPC instead of sending them.
+type SimulateSender struct {
+	Log *zap.Logger
+
+	rpc *rpc.Client
+}
+
+func NewSimulateSender(rpc *rpc.Client) *SimulateSender {
+	return &SimulateSender{
+		Log: zap.NewNop(),
+		rpc: rpc,
+	}
+}
+
+func (s *SimulateSender) Send(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	buf, err := tx.MarshalBinary()
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	// Nodes wrap the result in a context, which rpc.SimulateTransactionResponse doesn't expect.
+	var out struct {
+		Value *struct {
+			Err           interface{} `json:"err"`
+			Logs          []string    `json:"logs"`
+			UnitsConsumed *uint64     `json:"unitsConsumed"`
+		} `json:"value"`
+	}
+	opts := map[string]interface{}{
+		"encoding":   solana.EncodingBase64,
+		"sigVerify":  true,
+		"commitment": rpc.CommitmentProcessed,
+	}
+	err = s.rpc.RPCCallForInto(ctx, &out, "simulateTransaction",
+		[]interface{}{base64.StdEncoding.EncodeToString(buf), opts})
+	if err == nil && out.Value == nil {
+		err = errors.New("simulateTransaction() returned nil")
+	}
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	res := out.Value
+	if res.Err != nil {
+		s.Log.Debug("Simulation logs",
+			zap.Stringer("signature", tx.Signatures[0]),
+			zap.Strings("logs", res.Logs))
+		return solana.Signature{}, fmt.Errorf("simulation failed: %v", res.Err)
+	}
+	var units uint64
+	if res.UnitsConsumed != nil {
+		units = *res.UnitsConsumed
+	}
+	s.Log.Debug("Simulated transaction",
+		zap.Stringer("signature", tx.Signatures[0]),
+		zap.Uint64("units_consumed", units))
+	return tx.Signatures[0], nil
+}
+
+// FileSender writes transactions to a stream instead of sending them.
+//
+// Each transaction is written as a line of JSON with the base64-encoded wire format.
+type FileSender struct {
+	lock sync.Mutex
+	enc  *json.Encoder
+}
+
+func NewFileSender(wr io.Writer) *FileSender {
+	return &FileSender{enc: json.NewEncoder(wr)}
+}
+
+func (f *FileSender) Send(_ context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	buf, err := tx.MarshalBinary()
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	line := struct {
+		Time        time.Time        `json:"time"`
+		Signature   solana.Signature `json:"signature"`
+		Transaction string           `json:"transaction"`
+	}{
+		Time:        time.Now().UTC(),
+		Signature:   tx.Signatures[0],
+		Transaction: base64.StdEncoding.EncodeToString(buf),
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.enc.Encode(&line); err != nil {
+		return solana.Signature{}, err
+	}
+	return tx.Signatures[0], nil
+}
@@ -0,0 +1,91 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscardSender(t *testing.T) {
+	tx := newTestTx(t)
+	sig, err := DiscardSender{}.Send(context.Background(), tx)
+	require.NoError(t, err)
+	assert.Equal(t, tx.Signatures[0], sig)
+}
+
+func TestSimulateSender(t *testing.T) {
+	var simErr interface{}
+	client := newFakeRPC(t, func(method string, params []json.RawMessage) interface{} {
+		assert.Equal(t, "simulateTransaction", method)
+		if assert.Len(t, params, 2) {
+			assert.JSONEq(t, `{"encoding":"base64","sigVerify":true,"commitment":"processed"}`, string(params[1]))
+		}
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": 100},
+			"value": map[string]interface{}{
+				"err":           simErr,
+				"logs":          []string{"Program log: test"},
+				"unitsConsumed": 1500,
+			},
+		}
+	})
+	sender := NewSimulateSender(client)
+	tx := newTestTx(t)
+
+	sig, err := sender.Send(context.Background(), tx)
+	require.NoError(t, err)
+	assert.Equal(t, tx.Signatures[0], sig)
+
+	// Failed simulations are reported as send errors.
+	simErr = map[string]interface{}{"InstructionError": []interface{}{0, "InvalidArgument"}}
+	sig, err = sender.Send(context.Background(), tx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "simulation failed")
+	assert.Contains(t, err.Error(), "InvalidArgument")
+	assert.True(t, sig.IsZero())
+
+	// So are RPC errors.
+	sender = NewSimulateSender(rpc.New("http://127.0.0.1:1"))
+	_, err = sender.Send(context.Background(), tx)
+	assert.Error(t, err)
+}
+
+func TestFileSender(t *testing.T) {
+	var out bytes.Buffer
+	sender := NewFileSender(&out)
+	txs := []*solana.Transaction{newTestTx(t), newTestTx(t)}
+	for _, tx := range txs {
+		sig, err := sender.Send(context.Background(), tx)
+		require.NoError(t, err)
+		assert.Equal(t, tx.Signatures[0], sig)
+	}
+
+	// One line of JSON per transaction.
+	lines := bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n"))
+	require.Len(t, lines, len(txs))
+	for i, line := range lines {
+		var entry struct {
+			Time        time.Time        `json:"time"`
+			Signature   solana.Signature `json:"signature"`
+			Transaction string           `json:"transaction"`
+		}
+		require.NoError(t, json.Unmarshal(line, &entry))
+		assert.Equal(t, txs[i].Signatures[0], entry.Signature)
+		assert.WithinDuration(t, time.Now(), entry.Time, time.Minute)
+		assert.Equal(t, time.UTC, entry.Time.Location())
+
+		wire, err := base64.StdEncoding.DecodeString(entry.Transaction)
+		require.NoError(t, err)
+		expected, err := txs[i].MarshalBinary()
+		require.NoError(t, err)
+		assert.Equal(t, expected, wire)
+	}
+}
@@ -4,65 +4,212 @@ import (
 	"context"
 	"errors"
 	"net"
+	"net/url"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	eventbus "github.com/asaskevich/EventBus"
-	"github.com/cenkalti/backoff/v4"
 	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/gagliardetto/solana-go/rpc/ws"
 	"go.uber.org/zap"
 )
 
+// Slot sources in order of preference.
+const (
+	SlotSourceSlotsUpdates = "slots_updates" // slotsUpdatesSubscribe, first shred received
+	SlotSourceSlot         = "slot"          // slotSubscribe, slot processed
+	SlotSourcePoll         = "poll"          // getSlot polling, extrapolated
+)
+
+// SlotMonitor follows the slot the cluster is currently processing.
+//
+// It subscribes to all WebSocket endpoints at once and follows the highest slot seen.
+// Endpoints lagging by more than MaxLag slots are demoted:
+// Their stream is restarted and their updates ignored until they catch up.
+//
+// Per endpoint, it prefers slotsUpdatesSubscribe, which many RPC providers disable,
+// and falls back to slotSubscribe, then to getSlot polling if Poll is set.
+// Polling uses the HTTP(S) URL of the same endpoint.
 type SlotMonitor struct {
 	Log          *zap.Logger
-	WebSocketURL string
+	Poll         bool          // enables polling fallback
+	PollInterval time.Duration // time between getSlot calls when polling
+	MaxLag       uint64        // slots behind the highest slot before demoting an endpoint
+
+	endpoints []*slotEndpoint
+	sources   []slotSource // in order of preference
+	updates   chan *ws.SlotsUpdatesResult
+	lastSlot  uint64
+	bus       eventbus.Bus
+}
 
-	updates  chan *ws.SlotsUpdatesResult
-	lastSlot uint64
-	bus      eventbus.Bus
+type slotEndpoint struct {
+	url      string
+	rpc      *rpc.Client // for polling
+	name     string      // for logs and metrics, without credentials
+	source   int         // index of slot source to try first
+	received uint64      // number of updates received, atomic
+	slot     uint64      // last slot seen, atomic
+	demoted  int32       // atomic
+
+	lock   sync.Mutex
+	cancel context.CancelFunc // stops the current stream
 }
 
-func NewSlotMonitor(wsURL string) *SlotMonitor {
-	return &SlotMonitor{
+// NewSlotMonitor creates a new unstarted slot monitor following the given WebSocket endpoints.
+func NewSlotMonitor(wsURLs ...string) *SlotMonitor {
+	endpoints := make([]*slotEndpoint, len(wsURLs))
+	names := EndpointNames(wsURLs)
+	for i, wsURL := range wsURLs {
+		endpoints[i] = &slotEndpoint{url: wsURL, rpc: rpc.New(httpURL(wsURL)), name: names[i]}
+	}
+	s := &SlotMonitor{
 		Log:          zap.NewNop(),
-		WebSocketURL: wsURL,
+		PollInterval: 2 * time.Second,
+		MaxLag:       8,
+
+		endpoints: endpoints,
+		updates:   make(chan *ws.SlotsUpdatesResult, 1),
+		bus:       eventbus.New(),
+	}
+	s.sources = []slotSource{
+		{SlotSourceSlotsUpdates, s.runSlotsUpdates},
+		{SlotSourceSlot, s.runSlot},
+		{SlotSourcePoll, s.runPoll},
+	}
+	return s
+}
 
-		updates: make(chan *ws.SlotsUpdatesResult, 1),
-		bus:     eventbus.New(),
+// httpURL returns the HTTP(S) RPC URL of a WebSocket endpoint on the same host.
+func httpURL(wsURL string) string {
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return wsURL
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
 	}
+	return u.String()
 }
 
+// Run follows all endpoints until the context is cancelled.
 func (s *SlotMonitor) Run(ctx context.Context) error {
 	defer close(s.updates)
-	const retryInterval = 3 * time.Second
-	return backoff.Retry(func() error {
-		err := s.runConn(ctx)
+	var wg sync.WaitGroup
+	for _, endpoint := range s.endpoints {
+		wg.Add(1)
+		go func(e *slotEndpoint) {
+			defer wg.Done()
+			s.runEndpoint(ctx, e)
+		}(endpoint)
+	}
+	if len(s.endpoints) > 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runLagCheck(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *SlotMonitor) runEndpoint(ctx context.Context, e *slotEndpoint) {
+	log := s.Log.With(zap.String("endpoint", e.name))
+	_ = Reconnect(ctx, log, func(ctx context.Context) error {
+		runCtx, cancel := context.WithCancel(ctx)
+		e.lock.Lock()
+		e.cancel = cancel
+		e.lock.Unlock()
+		err := s.runSources(runCtx, e)
+		cancel()
 		switch {
-		case errors.Is(err, context.Canceled):
-			return backoff.Permanent(err)
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case runCtx.Err() != nil:
+			log.Warn("Endpoint lagging, restarting stream")
+			return nil
+		case err == nil:
+			return net.ErrClosed
 		default:
-			if ctxErr := ctx.Err(); ctxErr != nil {
-				return nil
-			}
-			s.Log.Error("Stream failed, restarting", zap.Error(err))
 			return err
 		}
-	}, backoff.WithContext(backoff.NewConstantBackOff(retryInterval), ctx))
+	})
 }
 
-func (s *SlotMonitor) runConn(ctx context.Context) error {
-	client, err := ws.Connect(ctx, s.WebSocketURL)
-	if err != nil {
-		return err
+type slotSource struct {
+	name string
+	run  func(context.Context, *slotEndpoint) error
+}
+
+// runSources runs slot sources in order of preference, starting from the last working one.
+//
+// A source failing after delivering updates gets retried.
+// A source failing without delivering any update is skipped in favor of the next,
+// wrapping around to the most preferred one.
+func (s *SlotMonitor) runSources(ctx context.Context, e *slotEndpoint) error {
+	var sources []slotSource
+	for _, source := range s.sources {
+		if source.name != SlotSourcePoll || s.Poll {
+			sources = append(sources, source)
+		}
 	}
-	defer client.Close()
 
+	var err error
+	for i := 0; i < len(sources); i++ {
+		source := sources[e.source%len(sources)]
+		setSlotSource(e.name, source.name)
+		before := atomic.LoadUint64(&e.received)
+		err = source.run(ctx, e)
+		if err == nil || errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			return err
+		}
+		if atomic.LoadUint64(&e.received) != before {
+			return err
+		}
+		s.Log.Warn("Slot source unavailable, falling back",
+			zap.String("endpoint", e.name),
+			zap.String("source", source.name),
+			zap.Error(err))
+		e.source = (e.source + 1) % len(sources)
+	}
+	return err
+}
+
+func setSlotSource(endpoint, active string) {
+	for _, source := range []string{SlotSourceSlotsUpdates, SlotSourceSlot, SlotSourcePoll} {
+		value := 0.0
+		if source == active {
+			value = 1
+		}
+		metricSlotSource.WithLabelValues(endpoint, source).Set(value)
+	}
+}
+
+func (s *SlotMonitor) connect(ctx context.Context, e *slotEndpoint) (*ws.Client, error) {
+	client, err := ws.Connect(ctx, e.url)
+	if err != nil {
+		return nil, err
+	}
 	// Make sure client cannot outlive context.
 	go func() {
 		defer client.Close()
 		<-ctx.Done()
 	}()
+	return client, nil
+}
+
+func (s *SlotMonitor) runSlotsUpdates(ctx context.Context, e *slotEndpoint) error {
+	client, err := s.connect(ctx, e)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
 
 	sub, err := client.SlotsUpdatesSubscribe()
 	if err != nil {
@@ -71,7 +218,7 @@ func (s *SlotMonitor) runConn(ctx context.Context) error {
 
 	// Stream updates.
 	for {
-		err := s.readNextUpdate(ctx, sub)
+		err := s.readNextUpdate(ctx, e, sub)
 		if errors.Is(err, context.Canceled) {
 			return nil
 		} else if err != nil {
@@ -80,20 +227,83 @@ func (s *SlotMonitor) runConn(ctx context.Context) error {
 	}
 }
 
-func (s *SlotMonitor) readNextUpdate(ctx context.Context, sub *ws.SlotsUpdatesSubscription) error {
-	// If no update comes in within 20 seconds, bail.
-	const readTimeout = 20 * time.Second
+func (s *SlotMonitor) runSlot(ctx context.Context, e *slotEndpoint) error {
+	client, err := s.connect(ctx, e)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	sub, err := client.SlotSubscribe()
+	if err != nil {
+		return err
+	}
+
+	// Stream updates.
+	for {
+		err := s.readNextSlot(ctx, e, sub)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// runPoll polls the current slot and extrapolates it between polls.
+func (s *SlotMonitor) runPoll(ctx context.Context, e *slotEndpoint) error {
+	poll := time.NewTicker(s.PollInterval)
+	defer poll.Stop()
+	tick := time.NewTicker(blockTime)
+	defer tick.Stop()
+
+	var polledSlot uint64
+	var polledAt time.Time
+	for {
+		pollCtx, cancel := context.WithTimeout(ctx, s.PollInterval)
+		slot, err := e.rpc.GetSlot(pollCtx, rpc.CommitmentProcessed)
+		cancel()
+		if err != nil {
+			return err
+		}
+		polledSlot, polledAt = slot, time.Now()
+		s.observe(e, polledSlot)
+
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-poll.C:
+				waiting = false
+			case now := <-tick.C:
+				s.observe(e, polledSlot+uint64(now.Sub(polledAt)/blockTime))
+			}
+		}
+	}
+}
+
+// readTimeout is the max time to wait for the next slot update before giving up on a stream.
+const readTimeout = 20 * time.Second
+
+// withReadTimeout returns a context that calls unsubscribe if it expires.
+func (s *SlotMonitor) withReadTimeout(ctx context.Context, unsubscribe func()) (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithTimeout(ctx, readTimeout)
-	defer cancel()
 	go func() {
 		<-ctx.Done()
 		// Terminate subscription if above timer has expired.
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			s.Log.Warn("Read deadline exceeded, terminating WebSocket connection",
 				zap.Duration("timeout", readTimeout))
-			sub.Unsubscribe()
+			unsubscribe()
 		}
 	}()
+	return ctx, cancel
+}
+
+func (s *SlotMonitor) readNextUpdate(ctx context.Context, e *slotEndpoint, sub *ws.SlotsUpdatesSubscription) error {
+	// If no update comes in within 20 seconds, bail.
+	ctx, cancel := s.withReadTimeout(ctx, sub.Unsubscribe)
+	defer cancel()
 
 	// Read next account update from WebSockets.
 	update, err := sub.Recv()
@@ -101,30 +311,116 @@ func (s *SlotMonitor) readNextUpdate(ctx context.Context, sub *ws.SlotsUpdatesSu
 		return err
 	} else if update == nil {
 		return net.ErrClosed
-	} else if update.Timestamp == nil {
-		ts := solana.UnixTimeSeconds(time.Now().Unix())
-		update.Timestamp = &ts
 	}
 
 	// Only listen for "first shred received" pings for now.
 	if update.Type != ws.SlotsUpdatesFirstShredReceived {
 		return nil
 	}
-	atomic.StoreUint64(&s.lastSlot, update.Slot)
+	s.observe(e, update.Slot)
+	return nil
+}
+
+func (s *SlotMonitor) readNextSlot(ctx context.Context, e *slotEndpoint, sub *ws.SlotSubscription) error {
+	// If no update comes in within 20 seconds, bail.
+	ctx, cancel := s.withReadTimeout(ctx, sub.Unsubscribe)
+	defer cancel()
+
+	update, err := sub.Recv()
+	if err != nil {
+		return err
+	} else if update == nil {
+		return net.ErrClosed
+	}
+	s.observe(e, update.Slot)
+	return nil
+}
+
+// observe records a slot seen by an endpoint and publishes it unless the endpoint is demoted.
+func (s *SlotMonitor) observe(e *slotEndpoint, slot uint64) {
+	atomic.AddUint64(&e.received, 1)
+	atomic.StoreUint64(&e.slot, slot)
+	if atomic.LoadInt32(&e.demoted) != 0 {
+		return
+	}
+	s.publish(slot)
+}
+
+// runLagCheck demotes and promotes endpoints by their lag until the context is cancelled.
+func (s *SlotMonitor) runLagCheck(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkLag()
+		}
+	}
+}
+
+func (s *SlotMonitor) checkLag() {
+	highest := s.Slot()
+	for _, e := range s.endpoints {
+		slot := atomic.LoadUint64(&e.slot)
+		if slot == 0 {
+			continue // still connecting
+		}
+		var lag uint64
+		if slot < highest {
+			lag = highest - slot
+		}
+		metricSlotEndpointLag.WithLabelValues(e.name).Set(float64(lag))
+
+		demoted := atomic.LoadInt32(&e.demoted) != 0
+		switch {
+		case !demoted && lag > s.MaxLag:
+			s.Log.Warn("Demoting lagging endpoint",
+				zap.String("endpoint", e.name),
+				zap.Uint64("lag", lag))
+			atomic.StoreInt32(&e.demoted, 1)
+			metricSlotEndpointDemoted.WithLabelValues(e.name).Set(1)
+			e.lock.Lock()
+			if e.cancel != nil {
+				e.cancel()
+			}
+			e.lock.Unlock()
+		case demoted && lag <= s.MaxLag:
+			s.Log.Info("Promoting endpoint that caught up", zap.String("endpoint", e.name))
+			atomic.StoreInt32(&e.demoted, 0)
+			metricSlotEndpointDemoted.WithLabelValues(e.name).Set(0)
+		}
+	}
+}
 
-	s.bus.Publish(busKey, update.Slot)
+// publish reports a new slot, ignoring slots not newer than the last one.
+func (s *SlotMonitor) publish(slot uint64) {
+	for {
+		last := atomic.LoadUint64(&s.lastSlot)
+		if slot <= last {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&s.lastSlot, last, slot) {
+			break
+		}
+	}
+
+	s.bus.Publish(busKey, slot)
 	metricSlotUpdates.Inc()
 
+	ts := solana.UnixTimeSeconds(time.Now().Unix())
+	update := &ws.SlotsUpdatesResult{
+		Slot:      slot,
+		Timestamp: &ts,
+		Type:      ws.SlotsUpdatesFirstShredReceived,
+	}
 	select {
-	case <-ctx.Done():
-		return ctx.Err()
 	case s.updates <- update:
-		s.Log.Debug("Slot update", zap.Uint64("slot", update.Slot))
+		s.Log.Debug("Slot update", zap.Uint64("slot", slot))
 	default:
-		s.Log.Warn("Dropping slot update", zap.Uint64("slot", update.Slot))
+		s.Log.Warn("Dropping slot update", zap.Uint64("slot", slot))
 	}
-
-	return nil
 }
 
 // Subscribe registers a callback function. The returned cancel func
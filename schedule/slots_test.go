@@ -0,0 +1,142 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlotMonitor_Lag(t *testing.T) {
+	slots := NewSlotMonitor("wss://a.example.com/key", "wss://b.example.com")
+	slots.MaxLag = 4
+	a, b := slots.endpoints[0], slots.endpoints[1]
+	assert.Equal(t, "a.example.com/key", a.name)
+
+	// Follow the highest slot of any endpoint.
+	slots.observe(a, 100)
+	slots.observe(b, 98)
+	assert.Equal(t, uint64(100), slots.Slot())
+	slots.observe(b, 102)
+	assert.Equal(t, uint64(102), slots.Slot())
+
+	// Demote an endpoint falling behind.
+	slots.observe(b, 110)
+	slots.checkLag()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&a.demoted))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&b.demoted))
+
+	// Updates of demoted endpoints are ignored.
+	slots.observe(a, 111)
+	assert.Equal(t, uint64(110), slots.Slot())
+
+	// Promote again once caught up.
+	slots.checkLag()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&a.demoted))
+	slots.observe(a, 112)
+	assert.Equal(t, uint64(112), slots.Slot())
+}
+
+func TestSlotMonitor_Names(t *testing.T) {
+	slots := NewSlotMonitor(
+		"wss://rpc.example.com/ws/8f2a9c1e4b7d6a3f0e5c",
+		"wss://rpc.example.com/ws/1b3d5f7a9c0e2b4d6f8a",
+		"wss://rpc.example.com/ws/1b3d5f7a9c0e2b4d6f8a",
+	)
+	names := make(map[string]bool)
+	for _, e := range slots.endpoints {
+		assert.NotContains(t, e.name, "8f2a9c1e4b7d6a3f0e5c")
+		assert.NotContains(t, e.name, "1b3d5f7a9c0e2b4d6f8a")
+		names[e.name] = true
+	}
+	assert.Len(t, names, 3, "distinct names on the same host")
+}
+
+func TestSlotMonitor_Fallback(t *testing.T) {
+	slots := NewSlotMonitor("wss://fallback.example.com")
+	slots.Poll = true
+	e := slots.endpoints[0]
+	var runs []string
+	fail := func(name string) func(context.Context, *slotEndpoint) error {
+		return func(context.Context, *slotEndpoint) error {
+			runs = append(runs, name)
+			return errors.New("method not found")
+		}
+	}
+	deliver := func(name string) func(context.Context, *slotEndpoint) error {
+		return func(_ context.Context, e *slotEndpoint) error {
+			runs = append(runs, name)
+			slots.observe(e, 100)
+			return errors.New("connection reset")
+		}
+	}
+	ctx := context.Background()
+
+	// Sources failing without updates fall back to the next one.
+	slots.sources = []slotSource{
+		{SlotSourceSlotsUpdates, fail(SlotSourceSlotsUpdates)},
+		{SlotSourceSlot, fail(SlotSourceSlot)},
+		{SlotSourcePoll, deliver(SlotSourcePoll)},
+	}
+	assert.Error(t, slots.runSources(ctx, e))
+	assert.Equal(t, []string{SlotSourceSlotsUpdates, SlotSourceSlot, SlotSourcePoll}, runs)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricSlotSource.WithLabelValues(e.name, SlotSourcePoll)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metricSlotSource.WithLabelValues(e.name, SlotSourceSlotsUpdates)))
+
+	// A source that delivered updates is retried first.
+	runs = nil
+	assert.Error(t, slots.runSources(ctx, e))
+	assert.Equal(t, []string{SlotSourcePoll}, runs)
+
+	// Wraps around to the preferred source once it works again.
+	runs = nil
+	slots.sources[0].run = deliver(SlotSourceSlotsUpdates)
+	slots.sources[2].run = fail(SlotSourcePoll)
+	assert.Error(t, slots.runSources(ctx, e))
+	assert.Equal(t, []string{SlotSourcePoll, SlotSourceSlotsUpdates}, runs)
+
+	// Polling is skipped unless enabled.
+	runs = nil
+	slots.Poll = false
+	slots.sources[0].run = fail(SlotSourceSlotsUpdates)
+	assert.Error(t, slots.runSources(ctx, e))
+	assert.Equal(t, []string{SlotSourceSlotsUpdates, SlotSourceSlot}, runs)
+}
+
+func TestSlotMonitor_PollPerEndpoint(t *testing.T) {
+	slots := NewSlotMonitor("wss://a.example.com/key", "ws://b.example.com:8900")
+	slots.PollInterval = time.Hour
+	a, b := slots.endpoints[0], slots.endpoints[1]
+	assert.Equal(t, "https://a.example.com/key", httpURL(a.url))
+	assert.Equal(t, "http://b.example.com:8900", httpURL(b.url))
+
+	// Each endpoint polls its own node.
+	for e, slot := range map[*slotEndpoint]uint64{a: 500, b: 490} {
+		slot := slot
+		e.rpc = newFakeRPC(t, func(method string, _ []json.RawMessage) interface{} {
+			assert.Equal(t, "getSlot", method)
+			return slot
+		})
+	}
+	for _, e := range []*slotEndpoint{a, b} {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func(e *slotEndpoint) {
+			done <- slots.runPoll(ctx, e)
+		}(e)
+		require.Eventually(t, func() bool {
+			return atomic.LoadUint64(&e.received) > 0
+		}, time.Second, 5*time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+	}
+	assert.Equal(t, uint64(500), atomic.LoadUint64(&a.slot))
+	assert.Equal(t, uint64(490), atomic.LoadUint64(&b.slot))
+	assert.Equal(t, uint64(500), slots.Slot())
+}
@@ -0,0 +1,203 @@
+package schedule
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+)
+
+// PublishStats tracks whether submitted price updates land on chain.
+type PublishStats struct {
+	Window      int    // number of recent submissions per price account to consider
+	ExpirySlots uint64 // number of slots after which a submission is considered missed
+
+	lock   sync.Mutex
+	prices map[solana.PublicKey]*priceStats
+}
+
+// PriceStats summarizes the inclusion of price updates for one price account.
+type PriceStats struct {
+	Account           solana.PublicKey `json:"account"`
+	LastSubmittedSlot uint64           `json:"last_submitted_slot"`
+	LastSubmittedTime *time.Time       `json:"last_submitted_time,omitempty"`
+	LastSeenSlot      uint64           `json:"last_seen_slot"`
+	LastSeenPubSlot   uint64           `json:"last_seen_pub_slot"`
+	LastSeenTime      *time.Time       `json:"last_seen_time,omitempty"`
+	Submitted         int              `json:"submitted"`
+	Landed            int              `json:"landed"`
+	Missed            int              `json:"missed"`
+	InclusionRatio    float64          `json:"inclusion_ratio"`
+	AvgLandingSlots   float64          `json:"avg_landing_slots"`
+}
+
+type priceStats struct {
+	lastSubmittedSlot uint64
+	lastSubmittedTime time.Time
+	lastSeenSlot      uint64
+	lastSeenPubSlot   uint64
+	lastSeenTime      time.Time
+	submissions       []submission // oldest first
+}
+
+type submission struct {
+	publisher  solana.PublicKey
+	pubSlot    uint64
+	sentSlot   uint64
+	landedSlot uint64 // slot the transaction was processed in, zero if unknown
+	seen       bool   // update observed in the price account
+}
+
+// NewPublishStats creates a new empty publish stats tracker.
+func NewPublishStats() *PublishStats {
+	return &PublishStats{
+		Window:      100,
+		ExpirySlots: 150,
+		prices:      make(map[solana.PublicKey]*priceStats),
+	}
+}
+
+// RecordSubmission remembers price updates that were sent to the cluster at the given slot.
+func (p *PublishStats) RecordSubmission(updates []*pyth.Instruction, slot uint64) {
+	now := time.Now()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, ins := range updates {
+		update, ok := ins.Payload.(*pyth.CommandUpdPrice)
+		if !ok {
+			continue
+		}
+		accs := ins.Accounts()
+		stats := p.getPrice(accs[1].PublicKey)
+		stats.lastSubmittedSlot = slot
+		stats.lastSubmittedTime = now
+		stats.submissions = append(stats.submissions, submission{
+			publisher: accs[0].PublicKey,
+			pubSlot:   update.PubSlot,
+			sentSlot:  slot,
+		})
+		if over := len(stats.submissions) - p.Window; over > 0 {
+			stats.submissions = stats.submissions[over:]
+		}
+	}
+}
+
+// RecordLanded remembers the slot that the transaction carrying the given updates was processed in.
+func (p *PublishStats) RecordLanded(updates []*pyth.Instruction, slot uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, ins := range updates {
+		update, ok := ins.Payload.(*pyth.CommandUpdPrice)
+		if !ok {
+			continue
+		}
+		accs := ins.Accounts()
+		stats, ok := p.prices[accs[1].PublicKey]
+		if !ok {
+			continue
+		}
+		for i := range stats.submissions {
+			sub := &stats.submissions[i]
+			if sub.pubSlot == update.PubSlot && sub.publisher.Equals(accs[0].PublicKey) && sub.landedSlot == 0 {
+				sub.landedSlot = slot
+			}
+		}
+	}
+}
+
+// ObservePrice checks whether the given on-chain price account state contains any submitted updates.
+//
+// The slot argument is the slot at which the account state was observed.
+// Landing latency is only known from RecordLanded.
+func (p *PublishStats) ObservePrice(entry pyth.PriceAccountEntry, slot uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	stats, ok := p.prices[entry.Pubkey]
+	if !ok {
+		return
+	}
+	for i := range stats.submissions {
+		sub := &stats.submissions[i]
+		if sub.seen {
+			continue
+		}
+		for _, comp := range entry.Components {
+			if !comp.Publisher.Equals(sub.publisher) {
+				continue
+			}
+			if comp.Latest.PubSlot == sub.pubSlot || comp.Agg.PubSlot == sub.pubSlot {
+				sub.seen = true
+				stats.lastSeenSlot = slot
+				stats.lastSeenPubSlot = sub.pubSlot
+				stats.lastSeenTime = time.Now()
+			}
+			break
+		}
+	}
+}
+
+// Stats returns inclusion statistics of all tracked price accounts, relative to the current slot.
+func (p *PublishStats) Stats(currentSlot uint64) []PriceStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	list := make([]PriceStats, 0, len(p.prices))
+	for account, stats := range p.prices {
+		list = append(list, stats.summarize(account, currentSlot, p.ExpirySlots))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Account.String() < list[j].Account.String()
+	})
+	return list
+}
+
+func (p *PublishStats) getPrice(account solana.PublicKey) *priceStats {
+	stats, ok := p.prices[account]
+	if !ok {
+		stats = new(priceStats)
+		p.prices[account] = stats
+	}
+	return stats
+}
+
+func (s *priceStats) summarize(account solana.PublicKey, currentSlot uint64, expirySlots uint64) PriceStats {
+	out := PriceStats{
+		Account:           account,
+		LastSubmittedSlot: s.lastSubmittedSlot,
+		LastSeenSlot:      s.lastSeenSlot,
+		LastSeenPubSlot:   s.lastSeenPubSlot,
+		Submitted:         len(s.submissions),
+	}
+	if !s.lastSubmittedTime.IsZero() {
+		t := s.lastSubmittedTime
+		out.LastSubmittedTime = &t
+	}
+	if !s.lastSeenTime.IsZero() {
+		t := s.lastSeenTime
+		out.LastSeenTime = &t
+	}
+	var latencySum uint64
+	var latencyCount int
+	for _, sub := range s.submissions {
+		if sub.landedSlot != 0 {
+			latencyCount++
+			if sub.landedSlot > sub.sentSlot {
+				latencySum += sub.landedSlot - sub.sentSlot
+			}
+		}
+		switch {
+		case sub.seen || sub.landedSlot != 0:
+			out.Landed++
+		case sub.sentSlot+expirySlots < currentSlot:
+			out.Missed++
+		}
+	}
+	if settled := out.Landed + out.Missed; settled > 0 {
+		out.InclusionRatio = float64(out.Landed) / float64(settled)
+	}
+	if latencyCount > 0 {
+		out.AvgLandingSlots = float64(latencySum) / float64(latencyCount)
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package schedule
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+func newTestUpdate(publisher, price solana.PublicKey, pubSlot uint64) *pyth.Instruction {
+	return pyth.NewInstructionBuilder(pyth.Devnet.Program).
+		UpdPriceNoFailOnError(publisher, price, pyth.CommandUpdPrice{Status: pyth.PriceStatusTrading, Price: 100, Conf: 1, PubSlot: pubSlot})
+}
+
+func TestPublishStats(t *testing.T) {
+	stats := NewPublishStats()
+	stats.ExpirySlots = 10
+	publisher, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	first := []*pyth.Instruction{newTestUpdate(publisher, price, 99)}
+	second := []*pyth.Instruction{newTestUpdate(publisher, price, 100)}
+	missed := []*pyth.Instruction{newTestUpdate(publisher, price, 101)}
+	stats.RecordSubmission(first, 100)
+	stats.RecordSubmission(second, 101)
+	stats.RecordSubmission(missed, 102)
+
+	// Landing latency comes from the processed slot, not the slot the account change was seen at.
+	stats.RecordLanded(first, 102)
+	stats.ObservePrice(pyth.PriceAccountEntry{
+		Pubkey: price,
+		PriceAccount: &pyth.PriceAccount{
+			Components: [32]pyth.PriceComp{{Publisher: publisher, Latest: pyth.PriceInfo{PubSlot: 99}}},
+		},
+	}, 120)
+	// Seen only through the transaction status.
+	stats.RecordLanded(second, 105)
+
+	list := stats.Stats(120)
+	require.Len(t, list, 1)
+	s := list[0]
+	assert.Equal(t, 3, s.Submitted)
+	assert.Equal(t, 2, s.Landed)
+	assert.Equal(t, 1, s.Missed)
+	assert.Equal(t, uint64(120), s.LastSeenSlot)
+	assert.Equal(t, 3.0, s.AvgLandingSlots)
+}
@@ -0,0 +1,215 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"go.uber.org/zap"
+)
+
+// TPUTransport delivers serialized transactions to a leader's TPU.
+//
+// The address is the node's advertised TPU (UDP) address,
+// transports over other protocols derive their port from it.
+type TPUTransport interface {
+	Send(ctx context.Context, tpu *net.UDPAddr, packet []byte) error
+}
+
+// UDPTransport sends transactions as single UDP datagrams.
+type UDPTransport struct {
+	conn *net.UDPConn
+}
+
+// NewUDPTransport opens an unconnected UDP socket on an ephemeral port.
+func NewUDPTransport() (*UDPTransport, error) {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPTransport{conn: conn}, nil
+}
+
+func (u *UDPTransport) Send(_ context.Context, tpu *net.UDPAddr, packet []byte) error {
+	_, err := u.conn.WriteToUDP(packet, tpu)
+	return err
+}
+
+func (u *UDPTransport) Close() error {
+	return u.conn.Close()
+}
+
+// TPUSender sends transactions straight to the TPUs of the current and upcoming leaders,
+// skipping RPC forwarding.
+//
+// Call Run to keep the leader schedule and node addresses up to date.
+type TPUSender struct {
+	Log          *zap.Logger
+	Fanout       uint64        // upcoming slots whose leaders receive each transaction
+	NodeInterval time.Duration // time between cluster node refreshes
+	Transports   []TPUTransport
+
+	rpc   *rpc.Client
+	slots *SlotMonitor
+
+	lock        sync.RWMutex
+	leaderStart uint64             // slot of first cached leader
+	leaders     []solana.PublicKey // leaders of consecutive slots starting at leaderStart
+	nodes       map[solana.PublicKey]*net.UDPAddr
+}
+
+// NewTPUSender creates a new unstarted TPU sender.
+func NewTPUSender(rpc *rpc.Client, slots *SlotMonitor, transports ...TPUTransport) *TPUSender {
+	return &TPUSender{
+		Log:          zap.NewNop(),
+		Fanout:       12, // leaders rotate every 4 slots
+		NodeInterval: time.Minute,
+		Transports:   transports,
+		rpc:          rpc,
+		slots:        slots,
+		nodes:        make(map[solana.PublicKey]*net.UDPAddr),
+	}
+}
+
+// Run refreshes leaders and node addresses until the context is cancelled.
+func (t *TPUSender) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var nodesUpdated time.Time
+	for {
+		if time.Since(nodesUpdated) >= t.NodeInterval {
+			if err := t.refreshNodes(ctx); err != nil {
+				if ctx.Err() == nil {
+					t.Log.Warn("Failed to get cluster nodes", zap.Error(err))
+				}
+			} else {
+				nodesUpdated = time.Now()
+			}
+		}
+		if err := t.refreshLeaders(ctx); err != nil && ctx.Err() == nil {
+			t.Log.Warn("Failed to get slot leaders", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *TPUSender) refreshNodes(ctx context.Context) error {
+	res, err := t.rpc.GetClusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	nodes := make(map[solana.PublicKey]*net.UDPAddr, len(res))
+	for _, node := range res {
+		if node.TPU == nil {
+			continue
+		}
+		addr, err := net.ResolveUDPAddr("udp", *node.TPU)
+		if err != nil {
+			continue
+		}
+		nodes[node.Pubkey] = addr
+	}
+	t.setNodes(nodes)
+	t.Log.Debug("Updated cluster nodes", zap.Int("tpus", len(nodes)))
+	return nil
+}
+
+// refreshLeaders fetches upcoming slot leaders when the cache runs low.
+func (t *TPUSender) refreshLeaders(ctx context.Context) error {
+	const (
+		fetchSlots = 256
+		minAhead   = 64
+	)
+	slot := t.slots.Slot()
+	if slot == 0 {
+		return nil
+	}
+	t.lock.RLock()
+	end := t.leaderStart + uint64(len(t.leaders))
+	t.lock.RUnlock()
+	if slot >= t.leaderStart && slot+minAhead < end {
+		return nil
+	}
+	leaders, err := t.rpc.GetSlotLeaders(ctx, slot, fetchSlots)
+	if err != nil {
+		return err
+	}
+	t.setLeaders(slot, leaders)
+	return nil
+}
+
+func (t *TPUSender) setNodes(nodes map[solana.PublicKey]*net.UDPAddr) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.nodes = nodes
+}
+
+func (t *TPUSender) setLeaders(start uint64, leaders []solana.PublicKey) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.leaderStart = start
+	t.leaders = leaders
+}
+
+// targets returns the distinct TPU addresses of the leaders of the given slot and the Fanout-1 after.
+func (t *TPUSender) targets(slot uint64) []*net.UDPAddr {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	var addrs []*net.UDPAddr
+	seen := make(map[solana.PublicKey]bool)
+	for s := slot; s < slot+t.Fanout; s++ {
+		if s < t.leaderStart || s-t.leaderStart >= uint64(len(t.leaders)) {
+			continue
+		}
+		leader := t.leaders[s-t.leaderStart]
+		if seen[leader] {
+			continue
+		}
+		seen[leader] = true
+		if addr, ok := t.nodes[leader]; ok {
+			addrs = append(addrs, addr)
+		} else {
+			metricTPUPackets.WithLabelValues("unknown_leader").Inc()
+		}
+	}
+	return addrs
+}
+
+func (t *TPUSender) Send(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	packet, err := tx.MarshalBinary()
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	if len(t.Transports) == 0 {
+		return solana.Signature{}, fmt.Errorf("no TPU transports")
+	}
+	addrs := t.targets(t.slots.Slot())
+	if len(addrs) == 0 {
+		return solana.Signature{}, fmt.Errorf("no known leader TPU")
+	}
+	var sent int
+	var lastErr error
+	for _, addr := range addrs {
+		for _, transport := range t.Transports {
+			if err := transport.Send(ctx, addr, packet); err != nil {
+				lastErr = err
+				metricTPUPackets.WithLabelValues("error").Inc()
+				continue
+			}
+			sent++
+			metricTPUPackets.WithLabelValues("success").Inc()
+		}
+	}
+	if sent == 0 {
+		return solana.Signature{}, fmt.Errorf("failed to send to any leader TPU: %w", lastErr)
+	}
+	return tx.Signatures[0], nil
+}
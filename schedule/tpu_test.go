@@ -0,0 +1,238 @@
+package schedule
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLeader is a local UDP listener standing in for a leader's TPU.
+type fakeLeader struct {
+	pubkey  solana.PublicKey
+	conn    *net.UDPConn
+	packets chan []byte
+}
+
+func newFakeLeader(t *testing.T) *fakeLeader {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	leader := &fakeLeader{
+		pubkey:  solana.NewWallet().PublicKey(),
+		conn:    conn,
+		packets: make(chan []byte, 16),
+	}
+	go func() {
+		buf := make([]byte, MaxTransactionSize)
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			leader.packets <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+	return leader
+}
+
+func (f *fakeLeader) addr() *net.UDPAddr {
+	return f.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (f *fakeLeader) receive(t *testing.T) []byte {
+	select {
+	case packet := <-f.packets:
+		return packet
+	case <-time.After(time.Second):
+		t.Fatal("leader received no packet")
+		return nil
+	}
+}
+
+func newTestTx(t *testing.T) *solana.Transaction {
+	payer := solana.NewWallet()
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{NewSetComputeUnitPriceInstruction(1)},
+		solana.Hash{},
+		solana.TransactionPayer(payer.PublicKey()),
+	)
+	require.NoError(t, err)
+	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		return &payer.PrivateKey
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+func TestTPUSender_Send(t *testing.T) {
+	current, next, later := newFakeLeader(t), newFakeLeader(t), newFakeLeader(t)
+
+	transport, err := NewUDPTransport()
+	require.NoError(t, err)
+	defer transport.Close()
+
+	slots := NewSlotMonitor("")
+	atomic.StoreUint64(&slots.lastSlot, 100)
+	sender := NewTPUSender(nil, slots, transport)
+	sender.Fanout = 6
+	sender.setNodes(map[solana.PublicKey]*net.UDPAddr{
+		current.pubkey: current.addr(),
+		next.pubkey:    next.addr(),
+		later.pubkey:   later.addr(),
+	})
+	var leaders []solana.PublicKey
+	for _, leader := range []*fakeLeader{current, next, later} {
+		for i := 0; i < 4; i++ {
+			leaders = append(leaders, leader.pubkey)
+		}
+	}
+	sender.setLeaders(98, leaders)
+
+	tx := newTestTx(t)
+	sig, err := sender.Send(context.Background(), tx)
+	require.NoError(t, err)
+	assert.Equal(t, tx.Signatures[0], sig)
+
+	// Slots 100-105 are led by current and next, once each.
+	wire, err := tx.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, wire, current.receive(t))
+	assert.Equal(t, wire, next.receive(t))
+	select {
+	case <-later.packets:
+		t.Fatal("leader outside fanout received packet")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Empty(t, current.packets)
+}
+
+func TestTPUSender_SendUnknownLeaders(t *testing.T) {
+	slots := NewSlotMonitor("")
+	atomic.StoreUint64(&slots.lastSlot, 100)
+	transport, err := NewUDPTransport()
+	require.NoError(t, err)
+	defer transport.Close()
+	sender := NewTPUSender(nil, slots, transport)
+	sender.setLeaders(100, []solana.PublicKey{solana.NewWallet().PublicKey()})
+
+	_, err = sender.Send(context.Background(), newTestTx(t))
+	assert.EqualError(t, err, "no known leader TPU")
+}
+
+// fakeQUICLeader is a local QUIC listener standing in for a leader's QUIC TPU.
+type fakeQUICLeader struct {
+	listener *quic.Listener
+	packets  chan []byte
+	conns    int32
+}
+
+func newFakeQUICLeader(t *testing.T) *fakeQUICLeader {
+	cert, err := newTPUCertificate()
+	require.NoError(t, err)
+	listener, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{tpuALPN},
+		ClientAuth:   tls.RequireAnyClientCert,
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	leader := &fakeQUICLeader{
+		listener: listener,
+		packets:  make(chan []byte, 16),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&leader.conns, 1)
+			go func() {
+				for {
+					stream, err := conn.AcceptUniStream(context.Background())
+					if err != nil {
+						return
+					}
+					packet, err := io.ReadAll(stream)
+					if err != nil {
+						return
+					}
+					leader.packets <- packet
+				}
+			}()
+		}
+	}()
+	return leader
+}
+
+func (f *fakeQUICLeader) receive(t *testing.T) []byte {
+	select {
+	case packet := <-f.packets:
+		return packet
+	case <-time.After(time.Second):
+		t.Fatal("leader received no QUIC stream")
+		return nil
+	}
+}
+
+func TestTPUSender_SendQUIC(t *testing.T) {
+	leader := newFakeLeader(t)
+	quicLeader := newFakeQUICLeader(t)
+
+	transport, err := NewQUICTransport()
+	require.NoError(t, err)
+	defer transport.Close()
+	// The QUIC port usually sits next to the UDP port, the listener got an arbitrary one.
+	transport.PortOffset = quicLeader.listener.Addr().(*net.UDPAddr).Port - leader.addr().Port
+
+	slots := NewSlotMonitor("")
+	atomic.StoreUint64(&slots.lastSlot, 100)
+	sender := NewTPUSender(nil, slots, transport)
+	sender.setNodes(map[solana.PublicKey]*net.UDPAddr{leader.pubkey: leader.addr()})
+	sender.setLeaders(100, []solana.PublicKey{leader.pubkey})
+
+	// Each transaction goes on its own stream of a shared connection.
+	for i := 0; i < 3; i++ {
+		tx := newTestTx(t)
+		sig, err := sender.Send(context.Background(), tx)
+		require.NoError(t, err)
+		assert.Equal(t, tx.Signatures[0], sig)
+		wire, err := tx.MarshalBinary()
+		require.NoError(t, err)
+		assert.Equal(t, wire, quicLeader.receive(t))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&quicLeader.conns))
+	assert.Empty(t, leader.packets, "nothing sent over UDP")
+
+	// Closed connections are dialed again.
+	transport.lock.Lock()
+	for _, conn := range transport.conns {
+		require.NoError(t, conn.CloseWithError(0, ""))
+	}
+	transport.lock.Unlock()
+	_, err = sender.Send(context.Background(), newTestTx(t))
+	require.NoError(t, err)
+	quicLeader.receive(t)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&quicLeader.conns))
+}
+
+func TestTPUSender_NoTransports(t *testing.T) {
+	leader := newFakeLeader(t)
+	slots := NewSlotMonitor("")
+	atomic.StoreUint64(&slots.lastSlot, 100)
+	sender := NewTPUSender(nil, slots)
+	sender.setNodes(map[solana.PublicKey]*net.UDPAddr{leader.pubkey: leader.addr()})
+	sender.setLeaders(100, []solana.PublicKey{leader.pubkey})
+
+	_, err := sender.Send(context.Background(), newTestTx(t))
+	assert.EqualError(t, err, "no TPU transports")
+}
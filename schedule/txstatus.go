@@ -0,0 +1,271 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"go.blockdaemon.com/pyth"
+	"go.uber.org/zap"
+)
+
+// Transaction statuses reported by TxTracker.
+const (
+	TxStatusSent      = "sent"
+	TxStatusProcessed = "processed"
+	TxStatusConfirmed = "confirmed"
+	TxStatusFinalized = "finalized"
+	TxStatusFailed    = "failed"
+	TxStatusExpired   = "expired"
+)
+
+// TxStatus describes the outcome of a price update transaction.
+type TxStatus struct {
+	Signature solana.Signature   `json:"signature"`
+	Prices    []solana.PublicKey `json:"prices"`
+	Status    string             `json:"status"`
+	Slot      uint64             `json:"slot"` // slot sent at, or slot processed at once landed
+	Error     string             `json:"error,omitempty"`
+}
+
+// TxTracker follows sent transactions until they are finalized, fail, or expire.
+type TxTracker struct {
+	Log          *zap.Logger
+	PollInterval time.Duration
+	ExpirySlots  uint64 // slots after sending until an unseen transaction is considered expired, if its expiry is unknown
+	MaxResubmits int    // resubmissions of an expired quote before giving up
+
+	// Buffer receives expired updates that are still the latest quote. Optional.
+	Buffer *Buffer
+
+	// Stats receives the slots that transactions landed in. Optional.
+	Stats *PublishStats
+
+	// BlockHash estimates the block height to expire transactions at. Optional, falls back to ExpirySlots.
+	BlockHash *BlockHashMonitor
+
+	// Nonces reports advanced nonces, after which nonced transactions expire. Optional.
+	Nonces *NonceMonitor
+
+	rpc   *rpc.Client
+	slots *SlotMonitor
+
+	lock    sync.Mutex
+	pending map[solana.Signature]*trackedTx
+
+	subsLock sync.Mutex
+	subs     map[uint64]func(TxStatus)
+	subNonce uint64
+}
+
+type trackedTx struct {
+	updates              []*pyth.Instruction
+	prices               []solana.PublicKey
+	sentSlot             uint64
+	lastValidBlockHeight uint64 // zero if unknown or nonced
+	nonce                *Nonce
+	status               string
+}
+
+// NewTxTracker creates a new unstarted transaction tracker.
+func NewTxTracker(rpc *rpc.Client, slots *SlotMonitor) *TxTracker {
+	return &TxTracker{
+		Log:          zap.NewNop(),
+		PollInterval: time.Second,
+		ExpirySlots:  150,
+		MaxResubmits: 2,
+		rpc:          rpc,
+		slots:        slots,
+		pending:      make(map[solana.Signature]*trackedTx),
+		subs:         make(map[uint64]func(TxStatus)),
+	}
+}
+
+// Track starts following a transaction of the given batch that was sent at the given slot.
+func (t *TxTracker) Track(sig solana.Signature, batch Batch, slot uint64) {
+	prices := updatePrices(batch.Updates)
+	t.lock.Lock()
+	t.pending[sig] = &trackedTx{
+		updates:              batch.Updates,
+		prices:               prices,
+		sentSlot:             slot,
+		lastValidBlockHeight: batch.lastValidBlockHeight,
+		nonce:                batch.nonce,
+		status:               TxStatusSent,
+	}
+	t.lock.Unlock()
+	t.publish(TxStatus{Signature: sig, Prices: prices, Status: TxStatusSent, Slot: slot})
+}
+
+// Failed reports a transaction that could not be sent.
+func (t *TxTracker) Failed(sig solana.Signature, updates []*pyth.Instruction, slot uint64, err error) {
+	t.publish(TxStatus{Signature: sig, Prices: updatePrices(updates), Status: TxStatusFailed, Slot: slot, Error: err.Error()})
+}
+
+// Run polls signature statuses until the context is cancelled.
+func (t *TxTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.poll(ctx); err != nil && ctx.Err() == nil {
+				t.Log.Warn("Failed to poll signature statuses", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (t *TxTracker) poll(ctx context.Context) error {
+	// RPC nodes accept up to 256 signatures per request.
+	const batchSize = 256
+
+	t.lock.Lock()
+	sigs := make([]solana.Signature, 0, len(t.pending))
+	for sig := range t.pending {
+		sigs = append(sigs, sig)
+	}
+	t.lock.Unlock()
+
+	for len(sigs) > 0 {
+		batch := sigs
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		sigs = sigs[len(batch):]
+
+		res, err := t.rpc.GetSignatureStatuses(ctx, false, batch...)
+		if err != nil {
+			return err
+		}
+		if len(res.Value) != len(batch) {
+			return fmt.Errorf("expected %d signature statuses, got %d", len(batch), len(res.Value))
+		}
+		for i, sig := range batch {
+			t.update(sig, res.Value[i])
+		}
+	}
+	return nil
+}
+
+// update applies a signature status result and reports changes.
+func (t *TxTracker) update(sig solana.Signature, res *rpc.SignatureStatusesResult) {
+	t.lock.Lock()
+	tx, ok := t.pending[sig]
+	if !ok {
+		t.lock.Unlock()
+		return
+	}
+	status := TxStatus{Signature: sig, Prices: tx.prices, Slot: tx.sentSlot}
+	switch {
+	case res == nil:
+		reason := t.expired(tx)
+		if reason == "" {
+			t.lock.Unlock()
+			return
+		}
+		status.Status = TxStatusExpired
+		status.Error = reason
+	case res.Err != nil:
+		status.Status = TxStatusFailed
+		status.Slot = res.Slot
+		status.Error = fmt.Sprintf("%v", res.Err)
+	default:
+		status.Status = string(res.ConfirmationStatus)
+		status.Slot = res.Slot
+	}
+	if status.Status == tx.status {
+		t.lock.Unlock()
+		return
+	}
+	landed := tx.status == TxStatusSent && res != nil
+	if landed && res.Slot >= tx.sentSlot {
+		metricTxLandingSlots.Observe(float64(res.Slot - tx.sentSlot))
+	}
+	tx.status = status.Status
+	switch status.Status {
+	case TxStatusFinalized, TxStatusFailed, TxStatusExpired:
+		delete(t.pending, sig)
+	}
+	t.lock.Unlock()
+
+	if landed && res.Err == nil && t.Stats != nil {
+		t.Stats.RecordLanded(tx.updates, res.Slot)
+	}
+	t.publish(status)
+	if status.Status == TxStatusExpired {
+		t.resubmit(tx)
+	}
+}
+
+// expired returns why a transaction that was not seen yet can no longer land, or empty if it still can.
+func (t *TxTracker) expired(tx *trackedTx) string {
+	slotsPassed := t.slots.Slot() > tx.sentSlot+t.ExpirySlots
+	switch {
+	case tx.nonce != nil:
+		// Nonced transactions remain valid until the nonce advances.
+		// Wait for ExpirySlots too, in case the nonce advanced because the transaction landed.
+		if t.Nonces != nil && slotsPassed && t.Nonces.Advanced(*tx.nonce) {
+			return "transaction not seen before nonce advanced"
+		}
+	case tx.lastValidBlockHeight != 0 && t.BlockHash != nil:
+		if t.BlockHash.EstimateBlockHeight() > tx.lastValidBlockHeight {
+			return "transaction not seen before block hash expiry"
+		}
+	case slotsPassed:
+		return "transaction not seen before block hash expiry"
+	}
+	return ""
+}
+
+// resubmit queues the updates of an expired transaction again, if they are still the latest quotes.
+func (t *TxTracker) resubmit(tx *trackedTx) {
+	if t.Buffer == nil {
+		return
+	}
+	slot := t.slots.Slot()
+	for _, ins := range tx.updates {
+		if !t.Buffer.resubmit(ins, slot, t.MaxResubmits) {
+			continue
+		}
+		accs := ins.Accounts()
+		t.Log.Debug("Resubmitting expired update", zap.Stringer("price", accs[1].PublicKey))
+		metricUpdatesResubmitted.
+			WithLabelValues(accs[0].PublicKey.String(), accs[1].PublicKey.String()).
+			Inc()
+	}
+}
+
+func (t *TxTracker) publish(status TxStatus) {
+	t.Log.Debug("Transaction status",
+		zap.Stringer("signature", status.Signature),
+		zap.String("status", status.Status),
+		zap.String("error", status.Error))
+	metricTxStatus.WithLabelValues(status.Status).Inc()
+	t.subsLock.Lock()
+	defer t.subsLock.Unlock()
+	for _, callback := range t.subs {
+		callback(status)
+	}
+}
+
+// Subscribe registers a callback function for transaction status changes. The returned cancel func unsubscribes.
+//
+// The callback must not block.
+func (t *TxTracker) Subscribe(callback func(TxStatus)) context.CancelFunc {
+	t.subsLock.Lock()
+	defer t.subsLock.Unlock()
+	t.subNonce++
+	id := t.subNonce
+	t.subs[id] = callback
+	return func() {
+		t.subsLock.Lock()
+		defer t.subsLock.Unlock()
+		delete(t.subs, id)
+	}
+}
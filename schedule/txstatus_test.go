@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+// newFakeRPC serves JSON-RPC requests with the given handler, which returns the result of a call.
+//
+// The handler runs on the server goroutine, so it must not call t.FailNow.
+func newFakeRPC(t *testing.T, handler func(method string, params []json.RawMessage) interface{}) *rpc.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  handler(req.Method, req.Params),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return rpc.New(server.URL)
+}
+
+func TestTxTracker(t *testing.T) {
+	sigLanded := solana.Signature{1}
+	sigFailed := solana.Signature{2}
+	sigExpired := solana.Signature{3}
+	statuses := map[string]interface{}{
+		sigLanded.String(): map[string]interface{}{
+			"slot":               105,
+			"confirmations":      nil,
+			"err":                nil,
+			"confirmationStatus": "confirmed",
+		},
+		sigFailed.String(): map[string]interface{}{
+			"slot":               104,
+			"confirmations":      nil,
+			"err":                map[string]interface{}{"InstructionError": []interface{}{0, map[string]interface{}{"Custom": 1}}},
+			"confirmationStatus": "processed",
+		},
+	}
+	client := newFakeRPC(t, func(method string, params []json.RawMessage) interface{} {
+		assert.Equal(t, "getSignatureStatuses", method)
+		var sigs []string
+		assert.NoError(t, json.Unmarshal(params[0], &sigs))
+		value := make([]interface{}, len(sigs))
+		for i, sig := range sigs {
+			value[i] = statuses[sig]
+		}
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 110}, "value": value}
+	})
+
+	slots := NewSlotMonitor()
+	slots.publish(100)
+	buffer := NewBuffer()
+	stats := NewPublishStats()
+	tracker := NewTxTracker(client, slots)
+	tracker.Buffer = buffer
+	tracker.Stats = stats
+
+	var lock sync.Mutex
+	results := make(map[solana.Signature]TxStatus)
+	tracker.Subscribe(func(status TxStatus) {
+		lock.Lock()
+		defer lock.Unlock()
+		results[status.Signature] = status
+	})
+
+	publisher := solana.NewWallet().PublicKey()
+	newUpdate := func() []*pyth.Instruction {
+		buffer.PushUpdate(newTestUpdate(publisher, solana.NewWallet().PublicKey(), 100))
+		return buffer.Flush(0)
+	}
+	landed, failed, expired := newUpdate(), newUpdate(), newUpdate()
+	stats.RecordSubmission(landed, 100)
+	tracker.Track(sigLanded, Batch{Updates: landed}, 100)
+	tracker.Track(sigFailed, Batch{Updates: failed}, 100)
+	tracker.Track(sigExpired, Batch{Updates: expired}, 100)
+
+	ctx := context.Background()
+	require.NoError(t, tracker.poll(ctx))
+	assert.Equal(t, TxStatusConfirmed, results[sigLanded].Status)
+	assert.Equal(t, uint64(105), results[sigLanded].Slot)
+	assert.Equal(t, TxStatusFailed, results[sigFailed].Status)
+	assert.NotEmpty(t, results[sigFailed].Error)
+	assert.Equal(t, TxStatusSent, results[sigExpired].Status, "not expired yet")
+	assert.Equal(t, 5.0, stats.Stats(110)[0].AvgLandingSlots)
+
+	// Not seen before expiry, resubmitted with a new pub slot.
+	slots.publish(100 + tracker.ExpirySlots + 1)
+	require.NoError(t, tracker.poll(ctx))
+	assert.Equal(t, TxStatusExpired, results[sigExpired].Status)
+	resubmitted := buffer.Flush(0)
+	require.Len(t, resubmitted, 1)
+	assert.Equal(t, expired[0].Accounts()[1].PublicKey, resubmitted[0].Accounts()[1].PublicKey)
+	assert.Equal(t, uint64(251), resubmitted[0].Payload.(*pyth.CommandUpdPrice).PubSlot)
+
+	// Finished transactions are no longer polled.
+	tracker.lock.Lock()
+	assert.Len(t, tracker.pending, 1, "only the confirmed transaction awaits finalization")
+	tracker.lock.Unlock()
+}
+
+func TestTxTracker_Expiry(t *testing.T) {
+	client := newFakeRPC(t, func(_ string, params []json.RawMessage) interface{} {
+		var sigs []string
+		assert.NoError(t, json.Unmarshal(params[0], &sigs))
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 110}, "value": make([]interface{}, len(sigs))}
+	})
+	slots := NewSlotMonitor()
+	slots.publish(100)
+	now := time.Unix(1_700_000_000, 0)
+	chain := &fakeChain{height: 1000, blockhash: solana.Hash{1}}
+	nonce := Nonce{Account: solana.NewWallet().PublicKey(), Value: solana.Hash{9}}
+	nonces := &NonceMonitor{nonces: map[solana.PublicKey]*nonceState{nonce.Account: {Nonce: nonce}}}
+
+	tracker := NewTxTracker(client, slots)
+	tracker.BlockHash = newTestBlockHashMonitor(t, chain, &now)
+	tracker.Nonces = nonces
+	results := make(map[solana.Signature]string)
+	tracker.Subscribe(func(status TxStatus) {
+		results[status.Signature] = status.Status
+	})
+
+	sigHash, sigNonce := solana.Signature{1}, solana.Signature{2}
+	update := newTestUpdate(solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), 100)
+	tracker.Track(sigHash, Batch{Updates: []*pyth.Instruction{update}, lastValidBlockHeight: 1150}, 100)
+	tracker.Track(sigNonce, Batch{Updates: []*pyth.Instruction{update}, nonce: &nonce}, 100)
+	ctx := context.Background()
+
+	// Many slots passed, but the block hash is still valid.
+	slots.publish(100 + tracker.ExpirySlots + 50)
+	now = now.Add(60 * time.Second) // 150 blocks
+	require.NoError(t, tracker.poll(ctx))
+	assert.Equal(t, TxStatusSent, results[sigHash])
+	assert.Equal(t, TxStatusSent, results[sigNonce], "nonce not advanced")
+
+	// Block height passes the last valid height.
+	now = now.Add(time.Second)
+	require.NoError(t, tracker.poll(ctx))
+	assert.Equal(t, TxStatusExpired, results[sigHash])
+
+	// Nonced transactions expire once the nonce advanced.
+	nonces.nonces[nonce.Account].Value = solana.Hash{10}
+	require.NoError(t, tracker.poll(ctx))
+	assert.Equal(t, TxStatusExpired, results[sigNonce])
+}
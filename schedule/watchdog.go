@@ -0,0 +1,109 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+
+	"go.blockdaemon.com/pyth"
+	"go.uber.org/zap"
+)
+
+// Watchdog marks prices as unknown when their source stops sending fresh quotes.
+//
+// Attach it to a Buffer to observe quotes.
+// Stale prices are no longer republished by the Buffer's Heartbeat.
+type Watchdog struct {
+	Log      *zap.Logger
+	MaxSlots uint64 // slots without a fresh quote before the price is marked unknown
+
+	buffer *Buffer
+	lock   sync.Mutex
+	prices map[updateKey]*watchdogState
+}
+
+type watchdogState struct {
+	last  *pyth.Instruction // last quote received
+	slot  uint64            // publish slot of last quote
+	stale bool
+}
+
+// NewWatchdog creates a new unstarted watchdog pushing into the given buffer.
+func NewWatchdog(buffer *Buffer, maxSlots uint64) *Watchdog {
+	return &Watchdog{
+		Log:      zap.NewNop(),
+		MaxSlots: maxSlots,
+		buffer:   buffer,
+		prices:   make(map[updateKey]*watchdogState),
+	}
+}
+
+// Run checks for stale prices on every slot until the context is cancelled.
+func (w *Watchdog) Run(ctx context.Context, slots *SlotMonitor) {
+	cancel := slots.Subscribe(w.check)
+	defer cancel()
+	<-ctx.Done()
+}
+
+// observe records a fresh quote.
+func (w *Watchdog) observe(ins *pyth.Instruction) {
+	update, ok := ins.Payload.(*pyth.CommandUpdPrice)
+	if !ok {
+		return
+	}
+	accs := ins.Accounts()
+	publisher, price := accs[0].PublicKey, accs[1].PublicKey
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	key := updateKey{publisher: publisher, price: price}
+	state, ok := w.prices[key]
+	if !ok {
+		state = new(watchdogState)
+		w.prices[key] = state
+	}
+	if state.stale {
+		w.Log.Info("Price is fresh again", zap.Stringer("price", price))
+		metricStalePrices.WithLabelValues(publisher.String(), price.String()).Set(0)
+	}
+	state.last = ins
+	state.slot = update.PubSlot
+	state.stale = false
+}
+
+// check marks prices without a fresh quote in the last MaxSlots slots as unknown.
+func (w *Watchdog) check(slot uint64) {
+	var keys []updateKey
+	var insns []*pyth.Instruction
+	w.lock.Lock()
+	for key, state := range w.prices {
+		if state.stale || slot < state.slot+w.MaxSlots {
+			continue
+		}
+		state.stale = true
+		keys = append(keys, key)
+
+		publisher, price := key.publisher, key.price
+		w.Log.Warn("Price went stale, publishing unknown status",
+			zap.Stringer("price", price),
+			zap.Uint64("last_pub_slot", state.slot),
+			zap.Uint64("slot", slot))
+		metricStalePrices.WithLabelValues(publisher.String(), price.String()).Set(1)
+		metricStaleEvents.WithLabelValues(publisher.String(), price.String()).Inc()
+
+		insns = append(insns, pyth.NewInstructionBuilder(state.last.ProgramID()).
+			UpdPriceNoFailOnError(publisher, price, pyth.CommandUpdPrice{
+				Status:  pyth.PriceStatusUnknown,
+				PubSlot: slot,
+			}))
+	}
+	w.lock.Unlock()
+
+	if len(insns) == 0 {
+		return
+	}
+	// Stop the heartbeat first, so it can't replace the unknown status with the last quote.
+	if w.buffer.Heartbeat != nil {
+		w.buffer.Heartbeat.forget(keys...)
+	}
+	w.buffer.pushRepeated(insns)
+}
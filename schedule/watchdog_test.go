@@ -0,0 +1,64 @@
+package schedule
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+func TestWatchdog(t *testing.T) {
+	buffer := NewBuffer()
+	watchdog := NewWatchdog(buffer, 10)
+	buffer.Watchdog = watchdog
+	publisher, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	buffer.PushUpdate(newTestUpdate(publisher, price, 100))
+	require.Len(t, buffer.Flush(0), 1)
+
+	// Price is still fresh one slot before the threshold.
+	watchdog.check(109)
+	assert.Empty(t, buffer.Flush(0))
+
+	// Price goes stale at the threshold and unknown is published once.
+	watchdog.check(110)
+	insns := buffer.Flush(0)
+	require.Len(t, insns, 1)
+	assert.Equal(t, publisher, insns[0].Accounts()[0].PublicKey)
+	assert.Equal(t, price, insns[0].Accounts()[1].PublicKey)
+	update := insns[0].Payload.(*pyth.CommandUpdPrice)
+	assert.Equal(t, uint32(pyth.PriceStatusUnknown), update.Status)
+	assert.Equal(t, uint64(110), update.PubSlot)
+
+	watchdog.check(111)
+	watchdog.check(200)
+	assert.Empty(t, buffer.Flush(0))
+
+	// A fresh quote re-arms the watchdog.
+	buffer.PushUpdate(newTestUpdate(publisher, price, 300))
+	require.Len(t, buffer.Flush(0), 1)
+	watchdog.check(309)
+	assert.Empty(t, buffer.Flush(0))
+	watchdog.check(310)
+	insns = buffer.Flush(0)
+	require.Len(t, insns, 1)
+	assert.Equal(t, uint32(pyth.PriceStatusUnknown), insns[0].Payload.(*pyth.CommandUpdPrice).Status)
+}
+
+func TestWatchdog_Repeated(t *testing.T) {
+	buffer := NewBuffer()
+	watchdog := NewWatchdog(buffer, 10)
+	buffer.Watchdog = watchdog
+	publisher, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	buffer.PushUpdate(newTestUpdate(publisher, price, 100))
+	require.Len(t, buffer.Flush(0), 1)
+
+	// Repeated quotes don't count as fresh.
+	buffer.pushRepeated([]*pyth.Instruction{newTestUpdate(publisher, price, 105)})
+	require.Len(t, buffer.Flush(0), 1)
+	watchdog.check(110)
+	assert.Len(t, buffer.Flush(0), 1)
+}
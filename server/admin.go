@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/jsonrpc"
+	"go.blockdaemon.com/pythian/schedule"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AdminHandler serves privileged methods to operate Pythian at runtime.
+type AdminHandler struct {
+	Log      *zap.Logger
+	LogLevel zap.AtomicLevel
+	Guard    *Guard           // optional
+	Program  solana.PublicKey // Pyth program to republish released quotes to
+
+	buffer    *schedule.Buffer
+	scheduler *schedule.Scheduler
+	slots     *schedule.SlotMonitor
+	blockhash *schedule.BlockHashMonitor
+}
+
+func NewAdminHandler(
+	buffer *schedule.Buffer,
+	scheduler *schedule.Scheduler,
+	slots *schedule.SlotMonitor,
+	blockhash *schedule.BlockHashMonitor,
+) *AdminHandler {
+	return &AdminHandler{
+		Log:       zap.NewNop(),
+		LogLevel:  zap.NewAtomicLevel(),
+		buffer:    buffer,
+		scheduler: scheduler,
+		slots:     slots,
+		blockhash: blockhash,
+	}
+}
+
+// Register adds the admin methods to the given mux.
+//
+// All admin methods require authorization, see jsonrpc.BearerAuth.
+func (a *AdminHandler) Register(mux *jsonrpc.Mux) {
+	handle := func(method string, f jsonrpc.HandleFunc) {
+		mux.Handle(method, jsonrpc.RequireAuth(f))
+	}
+	handle("admin_pause", a.handlePause)
+	handle("admin_resume", a.handleResume)
+	handle("admin_drop_buffer", a.handleDropBuffer)
+	handle("admin_get_status", a.handleGetStatus)
+	handle("admin_release_quarantined", a.handleReleaseQuarantined)
+	handle("admin_set_log_level", a.handleSetLogLevel)
+}
+
+type adminAccountParams struct {
+	Account solana.PublicKey `json:"account"`
+}
+
+func (a *AdminHandler) handlePause(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	var params adminAccountParams
+	if req.Params != nil {
+		if err := decodeParams(req.Params, &params); err != nil {
+			return jsonrpc.NewInvalidParamsResponse(req.ID)
+		}
+	}
+	if params.Account.IsZero() {
+		a.Log.Warn("Pausing all publishing")
+		a.buffer.PauseAll()
+	} else {
+		a.Log.Warn("Pausing publishing", zap.Stringer("price", params.Account))
+		a.buffer.Pause(params.Account)
+	}
+	return jsonrpc.NewResultResponse(req.ID, 0)
+}
+
+func (a *AdminHandler) handleResume(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	var params adminAccountParams
+	if req.Params != nil {
+		if err := decodeParams(req.Params, &params); err != nil {
+			return jsonrpc.NewInvalidParamsResponse(req.ID)
+		}
+	}
+	if params.Account.IsZero() {
+		a.Log.Warn("Resuming all publishing")
+		a.buffer.ResumeAll()
+	} else {
+		a.Log.Warn("Resuming publishing", zap.Stringer("price", params.Account))
+		a.buffer.Resume(params.Account)
+	}
+	return jsonrpc.NewResultResponse(req.ID, 0)
+}
+
+func (a *AdminHandler) handleDropBuffer(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	dropped := a.buffer.Clear()
+	a.Log.Warn("Dropped buffered updates", zap.Int("updates", dropped))
+	var result struct {
+		Dropped int `json:"dropped"`
+	}
+	result.Dropped = dropped
+	return jsonrpc.NewResultResponse(req.ID, &result)
+}
+
+func (a *AdminHandler) handleGetStatus(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	hash := a.blockhash.Latest()
+	status := adminStatus{
+		Buffer:     a.buffer.Status(),
+		Scheduler:  a.scheduler.Status(),
+		Slot:       a.slots.Slot(),
+		Blockhash:  hash.Blockhash,
+		HashBlocks: hash.RemainingBlocks(time.Now()),
+		LogLevel:   a.LogLevel.Level().String(),
+	}
+	if a.Guard != nil {
+		status.Quarantined = a.Guard.Quarantined()
+	}
+	return jsonrpc.NewResultResponse(req.ID, &status)
+}
+
+// handleReleaseQuarantined removes a quote from the guard quarantine,
+// and if requested publishes it at the current slot, bypassing the guard.
+func (a *AdminHandler) handleReleaseQuarantined(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	var params struct {
+		Account   solana.PublicKey `json:"account"`
+		Publisher solana.PublicKey `json:"publisher"`
+		Publish   bool             `json:"publish"`
+	}
+	if err := decodeParams(req.Params, &params); err != nil || params.Account.IsZero() || params.Publisher.IsZero() {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+	if a.Guard == nil {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, "guard not enabled")
+	}
+	released, ok := a.Guard.Release(params.Publisher, params.Account)
+	if !ok {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrUnknownSymbol, "no quarantined update")
+	}
+	log := a.Log.With(
+		zap.Stringer("price", params.Account),
+		zap.Stringer("publisher", params.Publisher),
+		zap.String("rule", released.Violation.Rule))
+	if params.Publish {
+		update := released.Update
+		update.PubSlot = a.slots.Slot()
+		a.buffer.PushUpdate(pyth.NewInstructionBuilder(a.Program).
+			UpdPriceNoFailOnError(params.Publisher, params.Account, update))
+		log.Warn("Publishing quarantined update")
+	} else {
+		log.Info("Discarded quarantined update")
+	}
+	return jsonrpc.NewResultResponse(req.ID, &released)
+}
+
+func (a *AdminHandler) handleSetLogLevel(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	var params struct {
+		Level string `json:"level"`
+	}
+	if err := decodeParams(req.Params, &params); err != nil {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(params.Level)); err != nil {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
+	}
+	a.LogLevel.SetLevel(level)
+	a.Log.Info("Changed log level", zap.Stringer("level", level))
+	return jsonrpc.NewResultResponse(req.ID, 0)
+}
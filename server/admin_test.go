@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/jsonrpc"
+	"go.blockdaemon.com/pythian/schedule"
+	"go.uber.org/zap"
+)
+
+const testAdminToken = "secret"
+
+func newTestAdminHandler(t *testing.T) (*AdminHandler, *schedule.Buffer, http.Handler) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		var result interface{}
+		switch req.Method {
+		case "getBlockHeight":
+			result = 1000
+		case "getLatestBlockhash":
+			result = map[string]interface{}{
+				"context": map[string]interface{}{"slot": 1100},
+				"value": map[string]interface{}{
+					"blockhash":            solana.Hash{1}.String(),
+					"lastValidBlockHeight": 1150,
+				},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(node.Close)
+	client := rpc.New(node.URL)
+
+	buffer := schedule.NewBuffer()
+	blockhash, err := schedule.NewBlockHashMonitor(context.Background(), client)
+	require.NoError(t, err)
+	scheduler := schedule.NewScheduler(buffer, blockhash, nil, client)
+	slots := schedule.NewSlotMonitor("ws://localhost:1")
+
+	admin := NewAdminHandler(buffer, scheduler, slots, blockhash)
+	admin.Guard = NewGuard(NewPriceCache(nil))
+	admin.Program = pyth.Devnet.Program
+	mux := jsonrpc.NewMux()
+	admin.Register(mux)
+	return admin, buffer, jsonrpc.BearerAuth(testAdminToken, jsonrpc.NewServer(mux))
+}
+
+// callAdmin sends a JSON-RPC request and returns the decoded response.
+func callAdmin(t *testing.T, handler http.Handler, token string, method string, params interface{}) *jsonrpc.Response {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	if token != "" {
+		req.Header.Set("authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var res jsonrpc.Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return &res
+}
+
+func TestAdminHandler_Unauthorized(t *testing.T) {
+	_, buffer, handler := newTestAdminHandler(t)
+	methods := []string{
+		"admin_pause",
+		"admin_resume",
+		"admin_drop_buffer",
+		"admin_get_status",
+		"admin_release_quarantined",
+		"admin_set_log_level",
+	}
+	for _, method := range methods {
+		for _, token := range []string{"", "wrong"} {
+			res := callAdmin(t, handler, token, method, map[string]interface{}{"level": "debug"})
+			require.NotNil(t, res.Error, method)
+			assert.Equal(t, "Unauthorized", res.Error.Message, method)
+		}
+	}
+	assert.False(t, buffer.Status().Paused)
+}
+
+func TestAdminHandler_Pause(t *testing.T) {
+	_, buffer, handler := newTestAdminHandler(t)
+	price := solana.NewWallet().PublicKey()
+
+	res := callAdmin(t, handler, testAdminToken, "admin_pause", map[string]interface{}{"account": price.String()})
+	require.Nil(t, res.Error)
+	assert.Equal(t, []solana.PublicKey{price}, buffer.Status().PausedPrices)
+	res = callAdmin(t, handler, testAdminToken, "admin_resume", map[string]interface{}{"account": price.String()})
+	require.Nil(t, res.Error)
+	assert.Empty(t, buffer.Status().PausedPrices)
+
+	res = callAdmin(t, handler, testAdminToken, "admin_pause", nil)
+	require.Nil(t, res.Error)
+	assert.True(t, buffer.Status().Paused)
+	res = callAdmin(t, handler, testAdminToken, "admin_resume", nil)
+	require.Nil(t, res.Error)
+	assert.False(t, buffer.Status().Paused)
+}
+
+func TestAdminHandler_DropBuffer(t *testing.T) {
+	_, buffer, handler := newTestAdminHandler(t)
+	builder := pyth.NewInstructionBuilder(pyth.Devnet.Program)
+	publisher := solana.NewWallet().PublicKey()
+	for i := 0; i < 3; i++ {
+		buffer.PushUpdate(builder.UpdPriceNoFailOnError(publisher, solana.NewWallet().PublicKey(), pyth.CommandUpdPrice{}))
+	}
+
+	res := callAdmin(t, handler, testAdminToken, "admin_drop_buffer", nil)
+	require.Nil(t, res.Error)
+	assert.Equal(t, map[string]interface{}{"dropped": float64(3)}, res.Result)
+	assert.Empty(t, buffer.Status().Pending)
+}
+
+func TestAdminHandler_GetStatus(t *testing.T) {
+	_, _, handler := newTestAdminHandler(t)
+	res := callAdmin(t, handler, testAdminToken, "admin_get_status", nil)
+	require.Nil(t, res.Error)
+	status, ok := res.Result.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, solana.Hash{1}.String(), status["blockhash"])
+	assert.Contains(t, status, "buffer")
+	assert.Contains(t, status, "scheduler")
+}
+
+func TestAdminHandler_SetLogLevel(t *testing.T) {
+	admin, _, handler := newTestAdminHandler(t)
+	res := callAdmin(t, handler, testAdminToken, "admin_set_log_level", map[string]interface{}{"level": "debug"})
+	require.Nil(t, res.Error)
+	assert.Equal(t, zap.DebugLevel, admin.LogLevel.Level())
+
+	res = callAdmin(t, handler, testAdminToken, "admin_set_log_level", map[string]interface{}{"level": "loud"})
+	require.NotNil(t, res.Error)
+	assert.Equal(t, jsonrpc.ErrCodeInvalidParams, res.Error.Code)
+	assert.Equal(t, zap.DebugLevel, admin.LogLevel.Level())
+}
+
+func TestAdminHandler_ReleaseQuarantined(t *testing.T) {
+	admin, buffer, handler := newTestAdminHandler(t)
+	admin.Guard.NonNegative = true
+	admin.Guard.Quarantine = true
+	publisher, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	negative := &pyth.CommandUpdPrice{Status: pyth.PriceStatusTrading, Price: -5, Conf: 1}
+	params := map[string]interface{}{"account": price.String(), "publisher": publisher.String()}
+
+	// Discarding removes the update without publishing.
+	require.Error(t, admin.Guard.Check(publisher, price, negative))
+	res := callAdmin(t, handler, testAdminToken, "admin_release_quarantined", params)
+	require.Nil(t, res.Error)
+	assert.Empty(t, buffer.Status().Pending)
+	res = callAdmin(t, handler, testAdminToken, "admin_release_quarantined", params)
+	require.NotNil(t, res.Error)
+	assert.Equal(t, rpcErrUnknownSymbol, res.Error.Code)
+
+	// Publishing pushes the held update to the buffer.
+	require.Error(t, admin.Guard.Check(publisher, price, negative))
+	params["publish"] = true
+	res = callAdmin(t, handler, testAdminToken, "admin_release_quarantined", params)
+	require.Nil(t, res.Error)
+	assert.Equal(t, []solana.PublicKey{price}, buffer.Status().Pending)
+	assert.Empty(t, admin.Guard.Quarantined())
+}
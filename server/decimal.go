@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// quoteValue is a price or confidence value.
+//
+// Clients either send an integer that is already scaled by the price exponent,
+// or a decimal string that gets scaled by Pythian.
+type quoteValue struct {
+	scaled  int64
+	decimal *big.Rat // nil if value is already scaled
+}
+
+func (q quoteValue) IsZero() bool {
+	if q.decimal != nil {
+		return q.decimal.Sign() == 0
+	}
+	return q.scaled == 0
+}
+
+// scale returns the value as an integer in units of 10^exponent.
+//
+// Rounding errors larger than tolerance (in units of 10^exponent) are rejected.
+func (q quoteValue) scale(exponent int32, tolerance float64) (int64, error) {
+	if q.decimal == nil {
+		return q.scaled, nil
+	}
+
+	// Shift decimal point by exponent.
+	factor := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt32(exponent))), nil))
+	exact := new(big.Rat).Set(q.decimal)
+	if exponent < 0 {
+		exact.Mul(exact, factor)
+	} else {
+		exact.Quo(exact, factor)
+	}
+
+	// Round half away from zero.
+	quo, rem := new(big.Int).QuoRem(exact.Num(), exact.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(exact.Denom()) >= 0 {
+		if exact.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("value %s overflows int64 at exponent %d", q.decimal.FloatString(12), exponent)
+	}
+
+	// Check precision loss.
+	lost, _ := new(big.Rat).Sub(exact, new(big.Rat).SetInt(quo)).Float64()
+	if math.Abs(lost) > tolerance {
+		return 0, fmt.Errorf("value %s loses precision at exponent %d", q.decimal.FloatString(12), exponent)
+	}
+	return quo.Int64(), nil
+}
+
+func parseQuoteValue(data interface{}) (quoteValue, error) {
+	switch v := data.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return quoteValue{}, errors.New("scaled value must be an integer, use a string for decimals")
+		}
+		// float64(math.MaxInt64) rounds up to 2^63, which does not fit.
+		if v < math.MinInt64 || v >= math.MaxInt64 {
+			return quoteValue{}, errors.New("scaled value out of range, use a string for large values")
+		}
+		return quoteValue{scaled: int64(v)}, nil
+	case int64:
+		return quoteValue{scaled: v}, nil
+	case string:
+		if strings.Contains(v, "/") {
+			return quoteValue{}, fmt.Errorf("invalid decimal: %q", v)
+		}
+		r, ok := new(big.Rat).SetString(v)
+		if !ok {
+			return quoteValue{}, fmt.Errorf("invalid decimal: %q", v)
+		}
+		return quoteValue{decimal: r}, nil
+	default:
+		return quoteValue{}, fmt.Errorf("unsupported value type %T", data)
+	}
+}
+
+// quoteValueHookFunc decodes numbers and decimal strings into quoteValue.
+func quoteValueHookFunc() mapstructure.DecodeHookFuncType {
+	return func(_ reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if t != reflect.TypeOf(quoteValue{}) {
+			return data, nil
+		}
+		return parseQuoteValue(data)
+	}
+}
+
+func absInt32(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
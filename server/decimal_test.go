@@ -0,0 +1,85 @@
+package server
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteValue_Scale(t *testing.T) {
+	cases := []struct {
+		name      string
+		data      interface{}
+		exponent  int32
+		tolerance float64
+		scaled    int64
+		err       bool
+	}{
+		{
+			name:     "scaled integer",
+			data:     float64(123456),
+			exponent: -8,
+			scaled:   123456,
+		},
+		{
+			name:     "decimal",
+			data:     "1.23456789",
+			exponent: -8,
+			scaled:   123456789,
+		},
+		{
+			name:     "negative decimal",
+			data:     "-42.5",
+			exponent: -2,
+			scaled:   -4250,
+		},
+		{
+			name:     "positive exponent",
+			data:     "12000",
+			exponent: 3,
+			scaled:   12,
+		},
+		{
+			name:     "precision loss",
+			data:     "1.234",
+			exponent: -2,
+			err:      true,
+		},
+		{
+			name:      "precision loss within tolerance",
+			data:      "1.236",
+			exponent:  -2,
+			tolerance: 0.5,
+			scaled:    124,
+		},
+		{
+			name:     "overflow",
+			data:     "100000000000000",
+			exponent: -8,
+			err:      true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := parseQuoteValue(tc.data)
+			require.NoError(t, err)
+			scaled, err := q.scale(tc.exponent, tc.tolerance)
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.scaled, scaled)
+			}
+		})
+	}
+}
+
+func TestParseQuoteValue_Invalid(t *testing.T) {
+	for _, data := range []interface{}{1.5, "1/3", "abc", true, math.Exp2(63), -math.Exp2(64)} {
+		_, err := parseQuoteValue(data)
+		assert.Error(t, err, "%v", data)
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+	"go.uber.org/zap"
+)
+
+// Guard rules.
+const (
+	GuardRuleAggDeviation  = "agg_deviation"
+	GuardRulePrevDeviation = "prev_deviation"
+	GuardRuleConfRatio     = "conf_ratio"
+	GuardRuleNegative      = "negative"
+)
+
+// Guard rejects price updates that violate sanity rules before they get published.
+//
+// Rules with a zero value are disabled.
+type Guard struct {
+	Log *zap.Logger
+
+	MaxAggDeviation  float64                   // max relative deviation from the on-chain aggregate price
+	MaxPrevDeviation float64                   // max relative deviation from our previous quote
+	MaxConfRatio     float64                   // max ratio of conf to price
+	NonNegative      bool                      // reject negative prices
+	AllowNegative    map[solana.PublicKey]bool // price accounts exempt from NonNegative
+	Quarantine       bool                      // hold violating updates for inspection instead of dropping them
+	QuarantineTTL    time.Duration             // time until quarantined updates expire
+	QuarantineMax    int                       // max quarantined updates, the oldest get evicted
+
+	prices      *PriceCache
+	now         func() time.Time
+	lock        sync.Mutex
+	previous    map[quoteKey]pyth.CommandUpdPrice
+	quarantined map[quoteKey]QuarantinedUpdate
+}
+
+// quoteKey identifies the quotes of one publisher for one price account.
+type quoteKey struct {
+	publisher solana.PublicKey
+	price     solana.PublicKey
+}
+
+// GuardViolation is returned when a price update violates a guard rule.
+type GuardViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (g *GuardViolation) Error() string {
+	return "guard violation: " + g.Message
+}
+
+// QuarantinedUpdate is a price update held back by the guard.
+type QuarantinedUpdate struct {
+	Publisher solana.PublicKey     `json:"publisher"`
+	Price     solana.PublicKey     `json:"price"`
+	Update    pyth.CommandUpdPrice `json:"update"`
+	Violation GuardViolation       `json:"violation"`
+	Time      time.Time            `json:"time"`
+}
+
+// NewGuard creates a new guard with all rules disabled.
+func NewGuard(prices *PriceCache) *Guard {
+	return &Guard{
+		Log:           zap.NewNop(),
+		QuarantineTTL: 10 * time.Minute,
+		QuarantineMax: 1000,
+		prices:        prices,
+		now:           time.Now,
+		previous:      make(map[quoteKey]pyth.CommandUpdPrice),
+		quarantined:   make(map[quoteKey]QuarantinedUpdate),
+	}
+}
+
+// Check returns a *GuardViolation if the given update must not be published.
+//
+// Accepted updates become the reference for subsequent previous quote checks of the same publisher.
+func (g *Guard) Check(publisher solana.PublicKey, price solana.PublicKey, update *pyth.CommandUpdPrice) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	key := quoteKey{publisher: publisher, price: price}
+	violation := g.check(key, update)
+	if violation == nil {
+		g.previous[key] = *update
+		return nil
+	}
+
+	g.Log.Warn("Price update violates guard",
+		zap.Stringer("publisher", publisher),
+		zap.Stringer("price", price),
+		zap.String("rule", violation.Rule),
+		zap.String("message", violation.Message),
+		zap.Int64("update_price", update.Price),
+		zap.Uint64("update_conf", update.Conf))
+	metricGuardViolations.
+		WithLabelValues(price.String(), violation.Rule).
+		Inc()
+	if g.Quarantine {
+		now := g.now()
+		g.expireQuarantined(now)
+		if _, ok := g.quarantined[key]; !ok && len(g.quarantined) >= g.QuarantineMax {
+			g.evictOldest()
+		}
+		g.quarantined[key] = QuarantinedUpdate{
+			Publisher: publisher,
+			Price:     price,
+			Update:    *update,
+			Violation: *violation,
+			Time:      now,
+		}
+	}
+	return violation
+}
+
+func (g *Guard) check(key quoteKey, update *pyth.CommandUpdPrice) *GuardViolation {
+	price := key.price
+	if g.NonNegative && update.Price < 0 && !g.AllowNegative[price] {
+		return &GuardViolation{
+			Rule:    GuardRuleNegative,
+			Message: fmt.Sprintf("negative price %d", update.Price),
+		}
+	}
+
+	// Other rules only apply to trading quotes, which contribute to the aggregate.
+	if update.Status != pyth.PriceStatusTrading {
+		return nil
+	}
+	if g.MaxConfRatio > 0 && update.Price != 0 {
+		ratio := float64(update.Conf) / math.Abs(float64(update.Price))
+		if ratio > g.MaxConfRatio {
+			return &GuardViolation{
+				Rule:    GuardRuleConfRatio,
+				Message: fmt.Sprintf("conf/price ratio %g exceeds %g", ratio, g.MaxConfRatio),
+			}
+		}
+	}
+	if g.MaxAggDeviation > 0 {
+		if entry, ok := g.prices.Lookup(price); ok && entry.Agg.Status == pyth.PriceStatusTrading {
+			if dev, ok := deviation(update.Price, entry.Agg.Price); ok && dev > g.MaxAggDeviation {
+				return &GuardViolation{
+					Rule:    GuardRuleAggDeviation,
+					Message: fmt.Sprintf("deviation %g from aggregate price %d exceeds %g", dev, entry.Agg.Price, g.MaxAggDeviation),
+				}
+			}
+		}
+	}
+	if g.MaxPrevDeviation > 0 {
+		if prev, ok := g.previous[key]; ok && prev.Status == pyth.PriceStatusTrading {
+			if dev, ok := deviation(update.Price, prev.Price); ok && dev > g.MaxPrevDeviation {
+				return &GuardViolation{
+					Rule:    GuardRulePrevDeviation,
+					Message: fmt.Sprintf("deviation %g from previous price %d exceeds %g", dev, prev.Price, g.MaxPrevDeviation),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Quarantined returns the most recent quarantined update of each publisher and price account.
+func (g *Guard) Quarantined() []QuarantinedUpdate {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.expireQuarantined(g.now())
+	updates := make([]QuarantinedUpdate, 0, len(g.quarantined))
+	for _, update := range g.quarantined {
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// Release removes the quarantined update of a publisher and price account, if not expired yet.
+func (g *Guard) Release(publisher solana.PublicKey, price solana.PublicKey) (QuarantinedUpdate, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.expireQuarantined(g.now())
+	key := quoteKey{publisher: publisher, price: price}
+	update, ok := g.quarantined[key]
+	delete(g.quarantined, key)
+	return update, ok
+}
+
+// expireQuarantined removes quarantined updates older than QuarantineTTL.
+//
+// Must be called with lock held.
+func (g *Guard) expireQuarantined(now time.Time) {
+	if g.QuarantineTTL <= 0 {
+		return
+	}
+	for key, update := range g.quarantined {
+		if now.Sub(update.Time) > g.QuarantineTTL {
+			delete(g.quarantined, key)
+		}
+	}
+}
+
+// evictOldest removes the oldest quarantined update.
+//
+// Must be called with lock held.
+func (g *Guard) evictOldest() {
+	var oldest quoteKey
+	var oldestTime time.Time
+	for key, update := range g.quarantined {
+		if oldestTime.IsZero() || update.Time.Before(oldestTime) {
+			oldest, oldestTime = key, update.Time
+		}
+	}
+	delete(g.quarantined, oldest)
+}
+
+// deviation returns the relative difference of value to ref.
+func deviation(value int64, ref int64) (float64, bool) {
+	if ref == 0 {
+		return 0, false
+	}
+	return math.Abs(float64(value)-float64(ref)) / math.Abs(float64(ref)), true
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+func TestGuard_Check(t *testing.T) {
+	publisher, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	guard := NewGuard(NewPriceCache(nil))
+	guard.MaxPrevDeviation = 0.1
+	guard.MaxConfRatio = 0.05
+	guard.NonNegative = true
+	guard.Quarantine = true
+
+	trading := func(price int64, conf uint64) *pyth.CommandUpdPrice {
+		return &pyth.CommandUpdPrice{Status: pyth.PriceStatusTrading, Price: price, Conf: conf}
+	}
+	assertRule := func(err error, rule string) {
+		var violation *GuardViolation
+		require.ErrorAs(t, err, &violation)
+		assert.Equal(t, rule, violation.Rule)
+	}
+
+	require.NoError(t, guard.Check(publisher, price, trading(1000, 10)))
+	require.NoError(t, guard.Check(publisher, price, trading(1050, 10)))
+	assertRule(guard.Check(publisher, price, trading(10500, 10)), GuardRulePrevDeviation)
+	assertRule(guard.Check(publisher, price, trading(1050, 100)), GuardRuleConfRatio)
+	assertRule(guard.Check(publisher, price, trading(-1050, 10)), GuardRuleNegative)
+
+	// Non-trading quotes are only checked for negative prices.
+	require.NoError(t, guard.Check(publisher, price, &pyth.CommandUpdPrice{Status: pyth.PriceStatusHalted, Price: 10500, Conf: 1000}))
+	assertRule(guard.Check(publisher, price, &pyth.CommandUpdPrice{Status: pyth.PriceStatusHalted, Price: -1}), GuardRuleNegative)
+
+	quarantined := guard.Quarantined()
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, int64(-1), quarantined[0].Update.Price)
+}
+
+func TestGuard_Quarantine(t *testing.T) {
+	price := solana.NewWallet().PublicKey()
+	guard := NewGuard(NewPriceCache(nil))
+	guard.NonNegative = true
+	guard.Quarantine = true
+	guard.QuarantineTTL = time.Minute
+	guard.QuarantineMax = 2
+	now := time.Unix(1_700_000_000, 0)
+	guard.now = func() time.Time { return now }
+
+	negative := &pyth.CommandUpdPrice{Status: pyth.PriceStatusTrading, Price: -1}
+	publishers := make([]solana.PublicKey, 3)
+	for i := range publishers {
+		publishers[i] = solana.NewWallet().PublicKey()
+		require.Error(t, guard.Check(publishers[i], price, negative))
+		now = now.Add(time.Second)
+	}
+
+	// The oldest update got evicted to stay within the cap.
+	quarantined := guard.Quarantined()
+	require.Len(t, quarantined, 2)
+	_, ok := guard.Release(publishers[0], price)
+	assert.False(t, ok)
+
+	// Released updates are removed from the quarantine.
+	released, ok := guard.Release(publishers[1], price)
+	require.True(t, ok)
+	assert.Equal(t, publishers[1], released.Publisher)
+	assert.Equal(t, GuardRuleNegative, released.Violation.Rule)
+	_, ok = guard.Release(publishers[1], price)
+	assert.False(t, ok)
+	assert.Len(t, guard.Quarantined(), 1)
+
+	// Updates expire after the TTL.
+	now = now.Add(time.Minute)
+	assert.Len(t, guard.Quarantined(), 0)
+	_, ok = guard.Release(publishers[2], price)
+	assert.False(t, ok)
+}
+
+func TestGuard_Check_Publishers(t *testing.T) {
+	publisherA, publisherB := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	price := solana.NewWallet().PublicKey()
+	guard := NewGuard(NewPriceCache(nil))
+	guard.MaxPrevDeviation = 0.1
+	guard.Quarantine = true
+
+	trading := func(price int64) *pyth.CommandUpdPrice {
+		return &pyth.CommandUpdPrice{Status: pyth.PriceStatusTrading, Price: price, Conf: 1}
+	}
+
+	// Each publisher is checked against its own previous quote.
+	require.NoError(t, guard.Check(publisherA, price, trading(1000)))
+	require.NoError(t, guard.Check(publisherB, price, trading(2000)))
+	require.NoError(t, guard.Check(publisherA, price, trading(1050)))
+	require.NoError(t, guard.Check(publisherB, price, trading(2050)))
+
+	// Quarantined updates of one publisher don't replace the other's.
+	require.Error(t, guard.Check(publisherA, price, trading(5000)))
+	require.Error(t, guard.Check(publisherB, price, trading(9000)))
+	quarantined := guard.Quarantined()
+	require.Len(t, quarantined, 2)
+	byPublisher := map[solana.PublicKey]int64{}
+	for _, q := range quarantined {
+		byPublisher[q.Publisher] = q.Update.Price
+	}
+	assert.Equal(t, map[solana.PublicKey]int64{publisherA: 5000, publisherB: 9000}, byPublisher)
+}
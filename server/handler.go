@@ -3,55 +3,96 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"sync/atomic"
+	"time"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/mitchellh/mapstructure"
 	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/aggregate"
+	"go.blockdaemon.com/pythian/history"
 	"go.blockdaemon.com/pythian/jsonrpc"
 	"go.blockdaemon.com/pythian/schedule"
 	"go.uber.org/zap"
 )
 
 const (
-	rpcErrUnknownSymbol = -32000
-	rpcErrNotReady      = -32002
+	rpcErrUnknownSymbol      = -32000
+	rpcErrMissingPermissions = -32001
+	rpcErrNotReady           = -32002
+	rpcErrGuard              = -32010
+	rpcErrMarketClosed       = -32011
 )
 
 type Handler struct {
 	*jsonrpc.Mux
-	Log       *zap.Logger
-	client    *pyth.Client
-	buffer    *schedule.Buffer
-	publisher solana.PublicKey
-	slots     *schedule.SlotMonitor
-	subNonce  uint64
+	Log *zap.Logger
+
+	// DecimalTolerance is the max rounding error when scaling decimal prices,
+	// in units of the smallest price increment.
+	DecimalTolerance float64
+
+	// Guard checks price updates before they get published. Optional.
+	Guard *Guard
+
+	// Stats tracks inclusion of published price updates. Optional.
+	Stats *schedule.PublishStats
+
+	// Products reports changes to the product catalog. Optional.
+	Products *ProductWatcher
+
+	// History stores past price updates. Optional.
+	History *history.Store
+
+	// Tracker reports outcomes of sent transactions. Optional.
+	Tracker *schedule.TxTracker
+
+	// MarketHours applies trading calendars to updates. Optional.
+	MarketHours *MarketHours
+
+	client     *pyth.Client
+	buffer     *schedule.Buffer
+	publishers []solana.PublicKey
+	slots      *schedule.SlotMonitor
+	prices     *PriceCache
+	subNonce   uint64
 }
 
 func NewHandler(
 	client *pyth.Client,
 	updateBuffer *schedule.Buffer,
-	publisher solana.PublicKey,
+	publishers []solana.PublicKey,
 	slots *schedule.SlotMonitor,
+	prices *PriceCache,
 ) *Handler {
 	mux := jsonrpc.NewMux()
 	h := &Handler{
-		Mux:       mux,
-		Log:       zap.NewNop(),
-		client:    client,
-		buffer:    updateBuffer,
-		publisher: publisher,
-		slots:     slots,
-		subNonce:  1,
+		Mux:        mux,
+		Log:        zap.NewNop(),
+		client:     client,
+		buffer:     updateBuffer,
+		publishers: publishers,
+		slots:      slots,
+		prices:     prices,
+		subNonce:   1,
 	}
 	mux.HandleFunc("get_product_list", h.handleGetProductList)
 	mux.HandleFunc("get_product", h.handleGetProduct)
 	mux.HandleFunc("get_all_products", h.handleGetAllProducts)
 	mux.HandleFunc("update_price", h.handleUpdatePrice)
+	mux.HandleFunc("update_prices", h.handleUpdatePrices)
 	mux.HandleFunc("subscribe_price", h.handleSubscribePrice)
 	mux.HandleFunc("subscribe_price_sched", h.handleSubscribePriceSchedule)
+	mux.HandleFunc("get_publish_stats", h.handleGetPublishStats)
+	mux.HandleFunc("get_aggregate_preview", h.handleGetAggregatePreview)
+	mux.HandleFunc("subscribe_product", h.handleSubscribeProduct)
+	mux.HandleFunc("subscribe_mapping", h.handleSubscribeMapping)
+	mux.HandleFunc("get_price_history", h.handleGetPriceHistory)
+	mux.HandleFunc("subscribe_tx_status", h.handleSubscribeTxStatus)
+	mux.HandleFunc("subscribe_publisher_price", h.handleSubscribePublisherPrice)
 	return h
 }
 
@@ -127,35 +168,369 @@ func (h *Handler) handleGetProduct(ctx context.Context, req jsonrpc.Request, _ j
 	return jsonrpc.NewResultResponse(req.ID, productToDetailJSON(entry, prices))
 }
 
-func (h *Handler) handleUpdatePrice(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+func (h *Handler) handleUpdatePrice(ctx context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	// Decode params.
+	var params updatePriceParams
+	if err := decodeParams(req.Params, &params); err != nil {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
+	}
+	if err := params.validate(); err != nil {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
+	}
+
+	// Assemble instruction.
+	ins, err := h.newUpdateInstruction(ctx, &params)
+	var violation *GuardViolation
+	if errors.As(err, &violation) {
+		return jsonrpc.NewErrorResponse(req.ID, jsonrpc.Error{
+			Code:    rpcErrGuard,
+			Message: violation.Error(),
+			Data:    violation,
+		})
+	} else if errors.Is(err, rpc.ErrNotFound) {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrUnknownSymbol, "unknown symbol")
+	} else if errors.Is(err, errNotPermissioned) {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrMissingPermissions, err.Error())
+	} else if errors.Is(err, errMarketClosed) {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrMarketClosed, err.Error())
+	} else if errors.Is(err, errCatalogNotLoaded) {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, err.Error())
+	} else if err != nil {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
+	}
+
+	// Push instruction to write buffer. (Will be picked up by scheduler)
+	h.buffer.PushUpdate(ins)
+
+	return jsonrpc.NewResultResponse(req.ID, 0)
+}
+
+func (h *Handler) handleUpdatePrices(ctx context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	// Decode params.
+	var items []interface{}
+	if err := decodeParams(req.Params, &items); err != nil || len(items) == 0 {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+
+	// Validate each update separately.
+	results := make([]updatePriceResult, len(items))
+	insns := make([]*pyth.Instruction, 0, len(items))
+	for i, item := range items {
+		var params updatePriceParams
+		err := decodeParams(item, &params)
+		if err == nil {
+			err = params.validate()
+		}
+		var ins *pyth.Instruction
+		if err == nil {
+			ins, err = h.newUpdateInstruction(ctx, &params)
+		}
+		var violation *GuardViolation
+		if errors.As(err, &violation) || errors.Is(err, errMarketClosed) || errors.Is(err, errCatalogNotLoaded) {
+			results[i] = updatePriceResult{Status: "rejected", Error: err.Error()}
+			continue
+		} else if err != nil {
+			results[i] = updatePriceResult{Status: "invalid", Error: err.Error()}
+			continue
+		}
+		results[i] = updatePriceResult{Status: "ok"}
+		insns = append(insns, ins)
+	}
+
+	// Push all valid instructions to write buffer at once.
+	h.buffer.PushUpdates(insns)
+
+	return jsonrpc.NewResultResponse(req.ID, results)
+}
+
+type updatePriceParams struct {
+	Account   solana.PublicKey `json:"account"`
+	Price     quoteValue       `json:"price"`
+	Conf      quoteValue       `json:"conf"`
+	Status    string           `json:"status"`
+	Publisher solana.PublicKey `json:"publisher"` // optional
+}
+
+func (p *updatePriceParams) validate() error {
+	switch {
+	case p.Account.IsZero():
+		return errors.New("missing account")
+	case p.Price.IsZero():
+		return errors.New("missing price")
+	case p.Conf.IsZero():
+		return errors.New("missing conf")
+	case p.Status == "":
+		return errors.New("missing status")
+	default:
+		return nil
+	}
+}
+
+// newUpdateInstruction assembles a price update instruction from validated params.
+func (h *Handler) newUpdateInstruction(ctx context.Context, params *updatePriceParams) (*pyth.Instruction, error) {
+	publisher, err := h.resolvePublisher(ctx, params.Account, params.Publisher)
+	if err != nil {
+		return nil, err
+	}
+	price, conf, err := h.scaleQuote(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	update := pyth.CommandUpdPrice{
+		Status:  statusFromString(params.Status),
+		Price:   price,
+		Conf:    conf,
+		PubSlot: h.slots.Slot(),
+	}
+	if h.MarketHours != nil {
+		if err := h.MarketHours.Check(ctx, publisher, params.Account, &update); err != nil {
+			return nil, err
+		}
+	}
+	if h.Guard != nil {
+		if err := h.Guard.Check(publisher, params.Account, &update); err != nil {
+			return nil, err
+		}
+	}
+	return pyth.NewInstructionBuilder(h.client.Env.Program).
+		UpdPriceNoFailOnError(publisher, params.Account, update), nil
+}
+
+// errNotPermissioned is returned when none of our publisher keys may publish to a price account.
+var errNotPermissioned = errors.New("publisher not permissioned for price account")
+
+// resolvePublisher picks the publisher key to quote a price account with.
+//
+// If requested is zero, routes to the first of our keys permissioned for the price account.
+func (h *Handler) resolvePublisher(ctx context.Context, account solana.PublicKey, requested solana.PublicKey) (solana.PublicKey, error) {
+	if !requested.IsZero() {
+		if !h.isPublisher(requested) {
+			return solana.PublicKey{}, fmt.Errorf("unknown publisher %s", requested)
+		}
+		return requested, nil
+	}
+	// Nothing to route with a single key.
+	if len(h.publishers) == 1 {
+		return h.publishers[0], nil
+	}
+	entry, err := h.prices.GetPrice(ctx, account)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	return h.findPublisher(&entry)
+}
+
+// findPublisher returns the first of our keys permissioned for the given price account.
+func (h *Handler) findPublisher(entry *pyth.PriceAccountEntry) (solana.PublicKey, error) {
+	for _, publisher := range h.publishers {
+		for _, comp := range entry.Components {
+			if comp.Publisher.Equals(publisher) {
+				return publisher, nil
+			}
+		}
+	}
+	return solana.PublicKey{}, errNotPermissioned
+}
+
+func (h *Handler) isPublisher(key solana.PublicKey) bool {
+	for _, publisher := range h.publishers {
+		if publisher.Equals(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// scaleQuote converts the price and conf params to integers in units of the price exponent.
+func (h *Handler) scaleQuote(ctx context.Context, params *updatePriceParams) (price int64, conf uint64, err error) {
+	// Only look up the exponent if there is a decimal value to scale.
+	var exponent int32
+	if params.Price.decimal != nil || params.Conf.decimal != nil {
+		acc, err := h.prices.GetPrice(ctx, params.Account)
+		if err != nil {
+			return 0, 0, err
+		}
+		exponent = acc.Exponent
+	}
+
+	price, err = params.Price.scale(exponent, h.DecimalTolerance)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid price: %w", err)
+	}
+	confInt, err := params.Conf.scale(exponent, h.DecimalTolerance)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid conf: %w", err)
+	}
+	if confInt < 0 {
+		return 0, 0, errors.New("invalid conf: negative value")
+	}
+	return price, uint64(confInt), nil
+}
+
+func (h *Handler) handleGetPublishStats(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
 	// Decode params.
 	var params struct {
 		Account solana.PublicKey `json:"account"`
-		Price   int64            `json:"price"`
-		Conf    uint64           `json:"conf"`
-		Status  string           `json:"status"`
+	}
+	if req.Params != nil {
+		if err := decodeParams(req.Params, &params); err != nil {
+			return jsonrpc.NewInvalidParamsResponse(req.ID)
+		}
+	}
+	if h.Stats == nil {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, "publish stats not available")
+	}
+
+	stats := h.Stats.Stats(h.slots.Slot())
+	if params.Account.IsZero() {
+		return jsonrpc.NewResultResponse(req.ID, stats)
+	}
+	for _, s := range stats {
+		if s.Account.Equals(params.Account) {
+			return jsonrpc.NewResultResponse(req.ID, []schedule.PriceStats{s})
+		}
+	}
+	return jsonrpc.NewResultResponse(req.ID, []schedule.PriceStats{})
+}
+
+func (h *Handler) handleGetAggregatePreview(ctx context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	// Decode params.
+	var params struct {
+		Account   solana.PublicKey `json:"account"`
+		Price     quoteValue       `json:"price"`
+		Conf      quoteValue       `json:"conf"`
+		Publisher solana.PublicKey `json:"publisher"` // optional
 	}
 	if err := decodeParams(req.Params, &params); err != nil {
 		return jsonrpc.NewInvalidParamsResponse(req.ID)
 	}
-	if params.Account.IsZero() || params.Price == 0 || params.Conf == 0 || params.Status == "" {
+	if params.Account.IsZero() || params.Price.IsZero() || params.Conf.IsZero() {
 		return jsonrpc.NewInvalidParamsResponse(req.ID)
 	}
 
-	// Assemble instruction.
-	update := pyth.CommandUpdPrice{
-		Status:  statusFromString(params.Status),
-		Price:   params.Price,
-		Conf:    params.Conf,
-		PubSlot: h.slots.Slot(),
+	// Retrieve current component quotes.
+	entry, err := h.prices.GetPrice(ctx, params.Account)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrUnknownSymbol, "unknown symbol")
+	} else if err != nil {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, "failed to get price acc: "+err.Error())
+	}
+	publisher := params.Publisher
+	if publisher.IsZero() {
+		publisher, err = h.findPublisher(&entry)
+		if err != nil {
+			return jsonrpc.NewErrorStringResponse(req.ID, rpcErrMissingPermissions, err.Error())
+		}
+	} else if !h.isPublisher(publisher) {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, fmt.Errorf("unknown publisher %s", publisher))
+	}
+	price, err := params.Price.scale(entry.Exponent, h.DecimalTolerance)
+	if err != nil {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
+	}
+	conf, err := params.Conf.scale(entry.Exponent, h.DecimalTolerance)
+	if err == nil && conf < 0 {
+		err = errors.New("negative conf")
+	}
+	if err != nil {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
 	}
-	ins := pyth.NewInstructionBuilder(h.client.Env.Program).
-		UpdPriceNoFailOnError(h.publisher, params.Account, update)
 
-	// Push instruction to write buffer. (Will be picked up by scheduler)
-	h.buffer.PushUpdate(ins)
+	// Substitute our quote and run aggregation.
+	slot := h.slots.Slot()
+	quotes := make([]aggregate.Quote, 0, len(entry.Components))
+	var found bool
+	for _, comp := range entry.Components {
+		if comp.Publisher.IsZero() {
+			continue
+		}
+		quote := aggregate.Quote{
+			Price:   comp.Latest.Price,
+			Conf:    comp.Latest.Conf,
+			Status:  comp.Latest.Status,
+			PubSlot: comp.Latest.PubSlot,
+		}
+		if comp.Publisher.Equals(publisher) {
+			quote = aggregate.Quote{
+				Price:   price,
+				Conf:    uint64(conf),
+				Status:  pyth.PriceStatusTrading,
+				PubSlot: slot,
+			}
+			found = true
+		}
+		quotes = append(quotes, quote)
+	}
+	if !found {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrMissingPermissions, "publisher not permissioned for price account")
+	}
+	result := aggregate.Compute(quotes, slot, aggregate.AccountParams(entry.PriceAccount))
 
-	return jsonrpc.NewResultResponse(req.ID, 0)
+	return jsonrpc.NewResultResponse(req.ID, &aggregatePreview{
+		Status:       statusToString(result.Status),
+		Price:        result.Price,
+		Conf:         result.Conf,
+		Exponent:     entry.Exponent,
+		NumQuotes:    result.NumQuotes,
+		Slot:         slot,
+		CurrentPrice: entry.Agg.Price,
+		CurrentConf:  entry.Agg.Conf,
+	})
+}
+
+func (h *Handler) handleGetPriceHistory(_ context.Context, req jsonrpc.Request, _ jsonrpc.Requester) *jsonrpc.Response {
+	const defaultLimit, maxLimit = 1000, 10000
+
+	// Decode params.
+	var params struct {
+		Account   solana.PublicKey `mapstructure:"account"`
+		Publisher string           `mapstructure:"publisher"` // pubkey, "aggregate", or empty for all
+		FromSlot  uint64           `mapstructure:"from_slot"`
+		ToSlot    uint64           `mapstructure:"to_slot"`
+		FromTime  time.Time        `mapstructure:"from_time"`
+		ToTime    time.Time        `mapstructure:"to_time"`
+		Limit     int              `mapstructure:"limit"`
+	}
+	if err := decodeParams(req.Params, &params); err != nil {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
+	}
+	if params.Account.IsZero() || params.Limit < 0 || params.Limit > maxLimit {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+	if params.Limit == 0 {
+		params.Limit = defaultLimit
+	}
+	query := history.Query{
+		Account:  params.Account,
+		FromSlot: params.FromSlot,
+		ToSlot:   params.ToSlot,
+		FromTime: params.FromTime,
+		ToTime:   params.ToTime,
+	}
+	switch params.Publisher {
+	case "":
+	case "aggregate":
+		query.Publisher = &solana.PublicKey{}
+	default:
+		publisher, err := solana.PublicKeyFromBase58(params.Publisher)
+		if err != nil {
+			return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
+		}
+		query.Publisher = &publisher
+	}
+	if h.History == nil {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, "price history not available")
+	}
+
+	records := make([]history.Record, 0)
+	err := h.History.Scan(query, func(record history.Record) bool {
+		records = append(records, record)
+		return len(records) < params.Limit
+	})
+	if err != nil {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, "failed to read price history: "+err.Error())
+	}
+	return jsonrpc.NewResultResponse(req.ID, records)
 }
 
 func (h *Handler) handleSubscribePrice(_ context.Context, req jsonrpc.Request, callback jsonrpc.Requester) *jsonrpc.Response {
@@ -166,6 +541,7 @@ func (h *Handler) handleSubscribePrice(_ context.Context, req jsonrpc.Request, c
 	// Decode params.
 	var params struct {
 		Account solana.PublicKey `json:"account"`
+		Fields  []string         `json:"fields"` // opt-in fields: ema, prev, components
 	}
 	if err := decodeParams(req.Params, &params); err != nil {
 		return jsonrpc.NewInvalidParamsResponse(req.ID)
@@ -173,14 +549,18 @@ func (h *Handler) handleSubscribePrice(_ context.Context, req jsonrpc.Request, c
 	if params.Account.IsZero() {
 		return jsonrpc.NewInvalidParamsResponse(req.ID)
 	}
+	fields, err := parsePriceUpdateFields(params.Fields)
+	if err != nil {
+		return jsonrpc.NewInvalidParamsErrorResponse(req.ID, err)
+	}
 
 	// Launch new subscription worker.
 	subID := h.newSubID()
-	go h.asyncSubscribePrice(params.Account, callback, subID)
+	go h.asyncSubscribePrice(params.Account, fields, callback, subID)
 	return newSubscriptionResponse(req.ID, subID)
 }
 
-func (h *Handler) asyncSubscribePrice(account solana.PublicKey, callback jsonrpc.Requester, subID uint64) {
+func (h *Handler) asyncSubscribePrice(account solana.PublicKey, fields priceUpdateFields, callback jsonrpc.Requester, subID uint64) {
 	h.Log.Debug("Subscribing to price updates",
 		zap.Stringer("program", h.client.Env.Program),
 		zap.Stringer("price", account))
@@ -193,19 +573,73 @@ func (h *Handler) asyncSubscribePrice(account solana.PublicKey, callback jsonrpc
 
 	handler := pyth.NewPriceEventHandler(stream)
 	handler.OnPriceChange(account, func(update pyth.PriceUpdate) {
-		price := priceUpdate{
+		err := callback.AsyncRequestJSONRPC(context.Background(), "notify_price", subscriptionUpdate{
+			Result:       newPriceUpdate(update.Account, update.CurrentInfo, fields),
+			Subscription: subID,
+		})
+		if err != nil {
+			h.Log.Warn("Failed to deliver async price update", zap.Error(err))
+		}
+	})
+
+	<-callback.Done()
+}
+
+func (h *Handler) handleSubscribePublisherPrice(_ context.Context, req jsonrpc.Request, callback jsonrpc.Requester) *jsonrpc.Response {
+	if req.ID == nil {
+		return nil
+	}
+
+	// Decode params.
+	var params struct {
+		Account   solana.PublicKey `json:"account"`
+		Publisher solana.PublicKey `json:"publisher"`
+	}
+	if err := decodeParams(req.Params, &params); err != nil {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+	if params.Account.IsZero() || params.Publisher.IsZero() {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+
+	// Launch new subscription worker.
+	subID := h.newSubID()
+	go h.asyncSubscribePublisherPrice(params.Account, params.Publisher, callback, subID)
+	return newSubscriptionResponse(req.ID, subID)
+}
+
+func (h *Handler) asyncSubscribePublisherPrice(account, publisher solana.PublicKey, callback jsonrpc.Requester, subID uint64) {
+	h.Log.Debug("Subscribing to publisher price updates",
+		zap.Stringer("price", account),
+		zap.Stringer("publisher", publisher))
+	defer h.Log.Debug("Unsubscribing from publisher price updates",
+		zap.Stringer("price", account),
+		zap.Stringer("publisher", publisher))
+
+	stream := h.client.StreamPriceAccounts()
+	defer stream.Close()
+
+	handler := pyth.NewPriceEventHandler(stream)
+	handler.OnComponentChange(account, publisher, func(update pyth.ComponentUpdate) {
+		price := publisherPriceUpdate{
+			Publisher: publisher,
 			Price:     update.CurrentInfo.Price,
 			Conf:      update.CurrentInfo.Conf,
 			Status:    statusToString(update.CurrentInfo.Status),
-			ValidSlot: update.Account.ValidSlot,
 			PubSlot:   update.CurrentInfo.PubSlot,
+			AggPrice:  update.Account.Agg.Price,
+			AggConf:   update.Account.Agg.Conf,
 		}
-		err := callback.AsyncRequestJSONRPC(context.Background(), "notify_price", subscriptionUpdate{
+		if prev := update.PreviousInfo; prev != nil {
+			price.PrevPrice = prev.Price
+			price.PrevConf = prev.Conf
+		}
+		err := callback.AsyncRequestJSONRPC(context.Background(), "notify_publisher_price", subscriptionUpdate{
 			Result:       &price,
 			Subscription: subID,
 		})
 		if err != nil {
-			h.Log.Warn("Failed to deliver async price update", zap.Error(err))
+			h.Log.Warn("Failed to deliver async publisher price update", zap.Error(err))
 		}
 	})
 
@@ -248,6 +682,146 @@ func (h *Handler) asyncSubscribePriceSchedule(callback jsonrpc.Requester, subID
 	})
 }
 
+func (h *Handler) handleSubscribeProduct(_ context.Context, req jsonrpc.Request, callback jsonrpc.Requester) *jsonrpc.Response {
+	if req.ID == nil {
+		return nil
+	}
+
+	// Decode params.
+	var params struct {
+		Account solana.PublicKey `json:"account"`
+	}
+	if err := decodeParams(req.Params, &params); err != nil {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+	if params.Account.IsZero() {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+	if h.Products == nil {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, "product notifications not available")
+	}
+
+	// Launch new subscription worker.
+	subID := h.newSubID()
+	go h.asyncSubscribeProducts(&params.Account, "notify_product", callback, subID)
+	return newSubscriptionResponse(req.ID, subID)
+}
+
+func (h *Handler) handleSubscribeMapping(_ context.Context, req jsonrpc.Request, callback jsonrpc.Requester) *jsonrpc.Response {
+	if req.ID == nil {
+		return nil
+	}
+	if h.Products == nil {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, "product notifications not available")
+	}
+
+	// Launch new subscription worker.
+	subID := h.newSubID()
+	go h.asyncSubscribeProducts(nil, "notify_mapping", callback, subID)
+	return newSubscriptionResponse(req.ID, subID)
+}
+
+func (h *Handler) asyncSubscribeProducts(product *solana.PublicKey, method string, callback jsonrpc.Requester, subID uint64) {
+	events := make(chan ProductEvent, 64)
+	unsub := h.Products.Subscribe(func(event ProductEvent) {
+		if product != nil && !event.Product.Equals(*product) {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+			h.Log.Warn("Dropping product event", zap.Uint64("subscription", subID))
+		}
+	})
+	defer unsub()
+
+	for {
+		select {
+		case <-callback.Done():
+			return
+		case event := <-events:
+			err := callback.AsyncRequestJSONRPC(context.Background(), method, subscriptionUpdate{
+				Result:       &event,
+				Subscription: subID,
+			})
+			if errors.Is(err, net.ErrClosed) {
+				return
+			} else if err != nil {
+				h.Log.Warn("Failed to deliver async product update", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (h *Handler) handleSubscribeTxStatus(_ context.Context, req jsonrpc.Request, callback jsonrpc.Requester) *jsonrpc.Response {
+	if req.ID == nil {
+		return nil
+	}
+
+	// Decode params.
+	var params struct {
+		Account solana.PublicKey `json:"account"`
+	}
+	if err := decodeParams(req.Params, &params); err != nil {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+	if params.Account.IsZero() {
+		return jsonrpc.NewInvalidParamsResponse(req.ID)
+	}
+	if h.Tracker == nil {
+		return jsonrpc.NewErrorStringResponse(req.ID, rpcErrNotReady, "transaction status not available")
+	}
+
+	// Launch new subscription worker.
+	subID := h.newSubID()
+	go h.asyncSubscribeTxStatus(params.Account, callback, subID)
+	return newSubscriptionResponse(req.ID, subID)
+}
+
+func (h *Handler) asyncSubscribeTxStatus(account solana.PublicKey, callback jsonrpc.Requester, subID uint64) {
+	updates := make(chan txStatusUpdate, 64)
+	unsub := h.Tracker.Subscribe(func(status schedule.TxStatus) {
+		var found bool
+		for _, price := range status.Prices {
+			if price.Equals(account) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+		select {
+		case updates <- txStatusUpdate{
+			Signature: status.Signature,
+			Status:    status.Status,
+			Slot:      status.Slot,
+			Error:     status.Error,
+		}:
+		default:
+			h.Log.Warn("Dropping transaction status", zap.Uint64("subscription", subID))
+		}
+	})
+	defer unsub()
+
+	for {
+		select {
+		case <-callback.Done():
+			return
+		case update := <-updates:
+			err := callback.AsyncRequestJSONRPC(context.Background(), "notify_tx_status", subscriptionUpdate{
+				Result:       &update,
+				Subscription: subID,
+			})
+			if errors.Is(err, net.ErrClosed) {
+				return
+			} else if err != nil {
+				h.Log.Warn("Failed to deliver async transaction status", zap.Error(err))
+			}
+		}
+	}
+}
+
 func newSubscriptionResponse(reqID interface{}, subID uint64) *jsonrpc.Response {
 	var result struct {
 		Subscription uint64 `json:"subscription"`
@@ -262,8 +836,11 @@ func (h *Handler) newSubID() uint64 {
 
 func decodeParams(params interface{}, out interface{}) error {
 	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		DecodeHook: mapstructure.TextUnmarshallerHookFunc(),
-		Result:     out,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.TextUnmarshallerHookFunc(),
+			quoteValueHookFunc(),
+		),
+		Result: out,
 	})
 	if err != nil {
 		return err
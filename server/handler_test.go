@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/jsonrpc"
+	"go.blockdaemon.com/pythian/schedule"
+)
+
+func newTestHandler() (*Handler, *schedule.Buffer) {
+	buffer := schedule.NewBuffer()
+	client := &pyth.Client{Env: pyth.Devnet}
+	publisher := solana.NewWallet().PublicKey()
+	slots := schedule.NewSlotMonitor("ws://localhost:1")
+	return NewHandler(client, buffer, []solana.PublicKey{publisher}, slots, NewPriceCache(client)), buffer
+}
+
+func TestHandler_UpdatePrice_Invalid(t *testing.T) {
+	h, _ := newTestHandler()
+	res := h.handleUpdatePrice(context.Background(), jsonrpc.Request{
+		ID: 1,
+		Params: map[string]interface{}{
+			"account": solana.NewWallet().PublicKey().String(),
+			"price":   float64(100),
+			"conf":    float64(1),
+		},
+	}, nil)
+	require.NotNil(t, res.Error)
+	assert.Equal(t, jsonrpc.ErrCodeInvalidParams, res.Error.Code)
+	assert.Equal(t, "missing status", res.Error.Data)
+}
+
+func TestHandler_UpdatePrices(t *testing.T) {
+	h, buffer := newTestHandler()
+	ctx := context.Background()
+	quote := func(price interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"account": solana.NewWallet().PublicKey().String(),
+			"price":   price,
+			"conf":    float64(1),
+			"status":  "trading",
+		}
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		res := h.handleUpdatePrices(ctx, jsonrpc.Request{ID: 1, Params: []interface{}{}}, nil)
+		require.NotNil(t, res.Error)
+		assert.Equal(t, jsonrpc.ErrCodeInvalidParams, res.Error.Code)
+	})
+
+	t.Run("Mixed", func(t *testing.T) {
+		invalid := quote(float64(100))
+		delete(invalid, "status")
+		negativeConf := quote(float64(100))
+		negativeConf["conf"] = float64(-1)
+		res := h.handleUpdatePrices(ctx, jsonrpc.Request{ID: 1, Params: []interface{}{
+			quote(float64(100)),
+			invalid,
+			negativeConf,
+		}}, nil)
+		require.Nil(t, res.Error)
+		assert.Equal(t, []updatePriceResult{
+			{Status: "ok"},
+			{Status: "invalid", Error: "missing status"},
+			{Status: "invalid", Error: "invalid conf: negative value"},
+		}, res.Result)
+		assert.Len(t, buffer.Flush(0), 1)
+	})
+
+	t.Run("AllValid", func(t *testing.T) {
+		res := h.handleUpdatePrices(ctx, jsonrpc.Request{ID: 1, Params: []interface{}{
+			quote(float64(100)),
+			quote(float64(200)),
+			quote(float64(300)),
+		}}, nil)
+		require.Nil(t, res.Error)
+		for _, result := range res.Result.([]updatePriceResult) {
+			assert.Equal(t, "ok", result.Status)
+		}
+		assert.Len(t, buffer.Flush(0), 3)
+		assert.Empty(t, buffer.Flush(0))
+	})
+}
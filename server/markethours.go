@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/calendar"
+	"go.blockdaemon.com/pythian/schedule"
+	"go.uber.org/zap"
+)
+
+// errMarketClosed is returned when rejecting a trading update outside of market hours.
+var errMarketClosed = errors.New("market closed")
+
+// errCatalogNotLoaded is returned when checking updates before the product catalog is known.
+var errCatalogNotLoaded = errors.New("product catalog not loaded yet")
+
+// MarketHours applies trading calendars to price updates.
+//
+// Price accounts get assigned calendars by the symbol and asset type of their product.
+// Assignments follow the product catalog, see LoadCatalog and ApplyProductEvent.
+type MarketHours struct {
+	Log *zap.Logger
+
+	config *calendar.Config
+	client *pyth.Client
+	buffer *schedule.Buffer
+	slots  *schedule.SlotMonitor
+
+	lock         sync.Mutex
+	loaded       bool
+	productAttrs map[solana.PublicKey]map[string]string
+	priceProduct map[solana.PublicKey]solana.PublicKey
+	calendars    map[solana.PublicKey]*calendar.Calendar // nil if trading around the clock
+	active       map[quoteKey]*marketState               // prices quoted with a calendar
+}
+
+type marketState struct {
+	calendar *calendar.Calendar
+	closed   bool // closing status already published
+}
+
+// NewMarketHours creates a new unstarted market hours tracker.
+func NewMarketHours(
+	config *calendar.Config,
+	client *pyth.Client,
+	buffer *schedule.Buffer,
+	slots *schedule.SlotMonitor,
+) *MarketHours {
+	return &MarketHours{
+		Log:          zap.NewNop(),
+		config:       config,
+		client:       client,
+		buffer:       buffer,
+		slots:        slots,
+		productAttrs: make(map[solana.PublicKey]map[string]string),
+		priceProduct: make(map[solana.PublicKey]solana.PublicKey),
+		calendars:    make(map[solana.PublicKey]*calendar.Calendar),
+		active:       make(map[quoteKey]*marketState),
+	}
+}
+
+// Check applies the calendar of a price account to an update.
+//
+// Outside of a session, trading updates get their status overridden,
+// or are rejected with errMarketClosed.
+func (m *MarketHours) Check(_ context.Context, publisher solana.PublicKey, price solana.PublicKey, update *pyth.CommandUpdPrice) error {
+	m.lock.Lock()
+	if !m.loaded {
+		m.lock.Unlock()
+		return errCatalogNotLoaded
+	}
+	cal := m.calendars[price]
+	if cal == nil {
+		m.lock.Unlock()
+		return nil
+	}
+	open := cal.IsOpen(time.Now())
+	if open && cal.ClosingStatus {
+		key := quoteKey{publisher: publisher, price: price}
+		if state, ok := m.active[key]; ok {
+			state.calendar, state.closed = cal, false
+		} else {
+			m.active[key] = &marketState{calendar: cal}
+		}
+	}
+	m.lock.Unlock()
+
+	if open || update.Status != pyth.PriceStatusTrading {
+		return nil
+	}
+	if cal.Mode == calendar.ModeReject {
+		return errMarketClosed
+	}
+	update.Status = statusFromString(cal.Status)
+	return nil
+}
+
+// LoadCatalog assigns calendars to all prices of a full catalog snapshot.
+func (m *MarketHours) LoadCatalog(catalog map[solana.PublicKey]ProductInfo) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.productAttrs = make(map[solana.PublicKey]map[string]string, len(catalog))
+	m.priceProduct = make(map[solana.PublicKey]solana.PublicKey)
+	m.calendars = make(map[solana.PublicKey]*calendar.Calendar)
+	for product, info := range catalog {
+		m.productAttrs[product] = info.Attrs
+		for _, price := range info.Prices {
+			m.priceProduct[price] = product
+		}
+		m.assignProduct(product)
+	}
+	m.loaded = true
+}
+
+// ApplyProductEvent updates calendar assignments after a catalog change.
+func (m *MarketHours) ApplyProductEvent(event ProductEvent) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	switch event.Type {
+	case ProductAdded, ProductChanged:
+		m.productAttrs[event.Product] = event.AttrDict
+		m.assignProduct(event.Product)
+	case ProductRemoved:
+		delete(m.productAttrs, event.Product)
+	case PriceAdded:
+		m.priceProduct[*event.Price] = event.Product
+		m.calendars[*event.Price] = m.config.Lookup(m.productAttrs[event.Product])
+	case PriceRemoved:
+		delete(m.priceProduct, *event.Price)
+		delete(m.calendars, *event.Price)
+		for key := range m.active {
+			if key.price.Equals(*event.Price) {
+				delete(m.active, key)
+			}
+		}
+	}
+}
+
+// assignProduct looks up the calendar of all prices of a product.
+//
+// Must be called with lock held.
+func (m *MarketHours) assignProduct(product solana.PublicKey) {
+	cal := m.config.Lookup(m.productAttrs[product])
+	for price, p := range m.priceProduct {
+		if p.Equals(product) {
+			m.calendars[price] = cal
+		}
+	}
+}
+
+// Run publishes closing statuses until the context is cancelled.
+func (m *MarketHours) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.publishClosing(now)
+		}
+	}
+}
+
+// publishClosing pushes the closing status of prices whose session ended.
+func (m *MarketHours) publishClosing(now time.Time) {
+	slot := m.slots.Slot()
+	if slot == 0 {
+		return
+	}
+	m.buffer.PushUpdates(m.closingUpdates(now, slot))
+}
+
+// closingUpdates returns closing status updates of quotes whose session ended since the last call.
+func (m *MarketHours) closingUpdates(now time.Time, slot uint64) []*pyth.Instruction {
+	var insns []*pyth.Instruction
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for key, state := range m.active {
+		if state.calendar.IsOpen(now) {
+			state.closed = false
+			continue
+		}
+		if state.closed {
+			continue
+		}
+		state.closed = true
+		m.Log.Info("Market closed, publishing closing status",
+			zap.Stringer("publisher", key.publisher),
+			zap.Stringer("price", key.price),
+			zap.String("status", state.calendar.Status))
+		insns = append(insns, pyth.NewInstructionBuilder(m.client.Env.Program).
+			UpdPriceNoFailOnError(key.publisher, key.price, pyth.CommandUpdPrice{
+				Status:  statusFromString(state.calendar.Status),
+				PubSlot: slot,
+			}))
+	}
+	return insns
+}
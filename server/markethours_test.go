@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/calendar"
+	"go.blockdaemon.com/pythian/schedule"
+)
+
+// testCalendarConfig trades around the clock except on a holiday.
+const testCalendarConfig = `{
+  "calendars": {
+    "equity": {
+      "time_zone": "UTC",
+      "sessions": [{"days": ["sun", "mon", "tue", "wed", "thu", "fri", "sat"], "open": "00:00", "close": "00:00"}],
+      "holidays": ["2030-01-01"],
+      "status": "halted",
+      "closing_status": true
+    }
+  },
+  "asset_types": {"Equity": "equity"}
+}`
+
+func newTestMarketHours(t *testing.T) *MarketHours {
+	path := filepath.Join(t.TempDir(), "calendar.json")
+	require.NoError(t, os.WriteFile(path, []byte(testCalendarConfig), 0o644))
+	config, err := calendar.Load(path)
+	require.NoError(t, err)
+	return NewMarketHours(config, &pyth.Client{Env: pyth.Devnet}, schedule.NewBuffer(), schedule.NewSlotMonitor())
+}
+
+func TestMarketHours_Catalog(t *testing.T) {
+	ctx := context.Background()
+	hours := newTestMarketHours(t)
+	publisher := solana.NewWallet().PublicKey()
+	equity, crypto := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	equityPrice, cryptoPrice := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	update := pyth.CommandUpdPrice{Status: pyth.PriceStatusTrading}
+	assert.ErrorIs(t, hours.Check(ctx, publisher, equityPrice, &update), errCatalogNotLoaded)
+
+	hours.LoadCatalog(map[solana.PublicKey]ProductInfo{
+		equity: {Attrs: map[string]string{"asset_type": "Equity"}, Prices: []solana.PublicKey{equityPrice}},
+		crypto: {Attrs: map[string]string{"asset_type": "Crypto"}, Prices: []solana.PublicKey{cryptoPrice}},
+	})
+	require.NoError(t, hours.Check(ctx, publisher, equityPrice, &update))
+	require.NoError(t, hours.Check(ctx, publisher, cryptoPrice, &update))
+	assert.Len(t, hours.active, 1, "only prices with a calendar are tracked")
+
+	// Asset type change reassigns the calendar of existing prices.
+	hours.ApplyProductEvent(ProductEvent{Type: ProductChanged, Product: crypto, AttrDict: map[string]string{"asset_type": "Equity"}})
+	assert.NotNil(t, hours.calendars[cryptoPrice])
+
+	// New price accounts inherit the calendar of their product.
+	newPrice := solana.NewWallet().PublicKey()
+	hours.ApplyProductEvent(ProductEvent{Type: PriceAdded, Product: equity, Price: &newPrice})
+	assert.NotNil(t, hours.calendars[newPrice])
+
+	hours.ApplyProductEvent(ProductEvent{Type: PriceRemoved, Product: equity, Price: &equityPrice})
+	assert.Nil(t, hours.calendars[equityPrice])
+	assert.Empty(t, hours.active)
+}
+
+func TestMarketHours_ClosingPublishers(t *testing.T) {
+	ctx := context.Background()
+	hours := newTestMarketHours(t)
+	product, price := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	hours.LoadCatalog(map[solana.PublicKey]ProductInfo{
+		product: {Attrs: map[string]string{"asset_type": "Equity"}, Prices: []solana.PublicKey{price}},
+	})
+
+	// Two publishers quoting the same price each get a closing status.
+	publisher1, publisher2 := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	for _, publisher := range []solana.PublicKey{publisher1, publisher2} {
+		update := pyth.CommandUpdPrice{Status: pyth.PriceStatusTrading}
+		require.NoError(t, hours.Check(ctx, publisher, price, &update))
+	}
+
+	holiday := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	insns := hours.closingUpdates(holiday, 100)
+	require.Len(t, insns, 2)
+	var publishers []solana.PublicKey
+	for _, ins := range insns {
+		publishers = append(publishers, ins.Accounts()[0].PublicKey)
+		assert.Equal(t, price, ins.Accounts()[1].PublicKey)
+		assert.Equal(t, pyth.PriceStatusHalted, ins.Payload.(*pyth.CommandUpdPrice).Status)
+	}
+	assert.ElementsMatch(t, []solana.PublicKey{publisher1, publisher2}, publishers)
+
+	// Closing status is only published once.
+	assert.Empty(t, hours.closingUpdates(holiday, 101))
+}
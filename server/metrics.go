@@ -0,0 +1,15 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricGuardViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pythian",
+		Subsystem: "guard",
+		Name:      "violations_total",
+		Help:      "Number of price updates rejected by the guard",
+	}, []string{"pyth_price", "rule"})
+)
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"go.blockdaemon.com/pyth"
+	"go.uber.org/zap"
+)
+
+// PriceCache keeps a local copy of on-chain price accounts.
+type PriceCache struct {
+	Log *zap.Logger
+
+	client   *pyth.Client
+	lock     sync.RWMutex
+	prices   map[solana.PublicKey]pyth.PriceAccountEntry
+	onUpdate []func(pyth.PriceAccountEntry)
+}
+
+// NewPriceCache creates a new empty price cache.
+func NewPriceCache(client *pyth.Client) *PriceCache {
+	return &PriceCache{
+		Log:    zap.NewNop(),
+		client: client,
+		prices: make(map[solana.PublicKey]pyth.PriceAccountEntry),
+	}
+}
+
+// Run streams price account updates into the cache.
+//
+// This method will return when the context is cancelled or the stream fails.
+func (c *PriceCache) Run(ctx context.Context) error {
+	stream := c.client.StreamPriceAccounts()
+	defer stream.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update, ok := <-stream.Updates():
+			if !ok {
+				return stream.Err()
+			}
+			c.store(update)
+		}
+	}
+}
+
+// GetPrice returns the given price account, fetching it if it is not cached yet.
+func (c *PriceCache) GetPrice(ctx context.Context, key solana.PublicKey) (pyth.PriceAccountEntry, error) {
+	c.lock.RLock()
+	entry, ok := c.prices[key]
+	c.lock.RUnlock()
+	if ok {
+		return entry, nil
+	}
+
+	entry, err := c.client.GetPriceAccount(ctx, key, rpc.CommitmentConfirmed)
+	if err != nil {
+		return pyth.PriceAccountEntry{}, err
+	}
+	c.store(entry)
+	return entry, nil
+}
+
+// Lookup returns the given price account if it is cached.
+func (c *PriceCache) Lookup(key solana.PublicKey) (pyth.PriceAccountEntry, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	entry, ok := c.prices[key]
+	return entry, ok
+}
+
+// OnUpdate registers a callback that gets invoked on every price account update.
+//
+// Must be called before Run.
+func (c *PriceCache) OnUpdate(callback func(pyth.PriceAccountEntry)) {
+	c.onUpdate = append(c.onUpdate, callback)
+}
+
+func (c *PriceCache) store(entry pyth.PriceAccountEntry) {
+	if entry.PriceAccount == nil {
+		return
+	}
+	c.lock.Lock()
+	c.prices[entry.Pubkey] = entry
+	c.lock.Unlock()
+	for _, callback := range c.onUpdate {
+		callback(entry)
+	}
+}
@@ -0,0 +1,479 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/schedule"
+	"go.uber.org/zap"
+)
+
+// Product event types.
+const (
+	ProductAdded   = "product_added"
+	ProductRemoved = "product_removed"
+	ProductChanged = "product_changed"
+	PriceAdded     = "price_added"
+	PriceRemoved   = "price_removed"
+)
+
+// ProductEvent describes a change to the product catalog.
+type ProductEvent struct {
+	Type        string            `json:"type"`
+	Product     solana.PublicKey  `json:"product"`
+	Price       *solana.PublicKey `json:"price,omitempty"`
+	AttrDict    map[string]string `json:"attr_dict,omitempty"`
+	ChangedKeys []string          `json:"changed_keys,omitempty"`
+}
+
+// ProductWatcher streams product and mapping accounts and reports changes to the catalog.
+//
+// pyth.Client only streams price accounts, which are read through the price cache.
+// Product and mapping accounts are streamed separately, and each change is applied on its own.
+type ProductWatcher struct {
+	Log *zap.Logger
+
+	client      *pyth.Client
+	prices      *PriceCache
+	lock        sync.Mutex
+	initialized bool
+	products    map[solana.PublicKey]*productState
+	mappings    map[solana.PublicKey]map[solana.PublicKey]bool // products listed per mapping account
+	onSync      []func(map[solana.PublicKey]ProductInfo)
+	pending     []ProductEvent // events yet to be published
+
+	emitLock sync.Mutex // serializes publishing, held without lock
+	subsLock sync.Mutex
+	subs     map[uint64]func(ProductEvent)
+	subNonce uint64
+}
+
+// ProductInfo is the catalog entry of a product.
+type ProductInfo struct {
+	Attrs  map[string]string
+	Prices []solana.PublicKey
+}
+
+type productState struct {
+	attrs      map[string]string
+	firstPrice solana.PublicKey
+	prices     map[solana.PublicKey]bool
+}
+
+// NewProductWatcher creates a new unstarted product watcher.
+func NewProductWatcher(client *pyth.Client, prices *PriceCache) *ProductWatcher {
+	return &ProductWatcher{
+		Log:      zap.NewNop(),
+		client:   client,
+		prices:   prices,
+		products: make(map[solana.PublicKey]*productState),
+		mappings: make(map[solana.PublicKey]map[solana.PublicKey]bool),
+		subs:     make(map[uint64]func(ProductEvent)),
+	}
+}
+
+// Run streams catalog changes until the context is cancelled.
+func (p *ProductWatcher) Run(ctx context.Context) error {
+	return schedule.Reconnect(ctx, p.Log, p.runConn)
+}
+
+func (p *ProductWatcher) runConn(ctx context.Context) error {
+	client, err := ws.Connect(ctx, p.client.WebSocketURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Make sure client cannot outlive context.
+	go func() {
+		defer client.Close()
+		<-ctx.Done()
+	}()
+
+	subs := make([]*ws.ProgramSubscription, 0, 2)
+	for _, accountType := range []uint32{pyth.AccountTypeMapping, pyth.AccountTypeProduct} {
+		sub, err := client.ProgramSubscribeWithOpts(
+			p.client.Env.Program,
+			rpc.CommitmentConfirmed,
+			solana.EncodingBase64,
+			[]rpc.RPCFilter{accountTypeFilter(accountType)},
+		)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+	}
+
+	// Take snapshot after subscribing, so no changes are missed in between.
+	if err := p.sync(ctx); err != nil {
+		return err
+	}
+
+	errs := make(chan error, len(subs))
+	for _, sub := range subs {
+		go func(sub *ws.ProgramSubscription) {
+			errs <- p.readLoop(ctx, sub)
+		}(sub)
+	}
+	return <-errs
+}
+
+func (p *ProductWatcher) readLoop(ctx context.Context, sub *ws.ProgramSubscription) error {
+	defer sub.Unsubscribe()
+	for {
+		update, err := sub.Recv()
+		if err != nil {
+			return err
+		} else if update == nil {
+			return errors.New("subscription closed")
+		}
+		var data []byte
+		if acc := update.Value.Account; acc != nil && acc.Data != nil {
+			data = acc.Data.GetBinary()
+		}
+		if err := p.handleAccount(ctx, update.Value.Pubkey, data); err != nil {
+			p.Log.Warn("Failed to process account update",
+				zap.Stringer("account", update.Value.Pubkey),
+				zap.Error(err))
+		}
+	}
+}
+
+func (p *ProductWatcher) handleAccount(ctx context.Context, key solana.PublicKey, data []byte) error {
+	switch pyth.PeekAccount(data) {
+	case pyth.AccountTypeMapping:
+		var mapping pyth.MappingAccount
+		if err := mapping.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		return p.applyMapping(ctx, key, mappingProducts(&mapping))
+	case pyth.AccountTypeProduct:
+		var product pyth.ProductAccount
+		if err := product.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		return p.applyProductAccount(ctx, key, product.FirstPrice, product.Attrs.KVs())
+	default:
+		// Account closed or reassigned.
+		p.lock.Lock()
+		p.removeProduct(key)
+		p.lock.Unlock()
+		p.flush()
+		return nil
+	}
+}
+
+// applyMapping adds and removes the products that changed in a mapping account.
+func (p *ProductWatcher) applyMapping(ctx context.Context, key solana.PublicKey, products map[solana.PublicKey]bool) error {
+	p.lock.Lock()
+	before := p.mappings[key]
+	p.mappings[key] = products
+	var added []solana.PublicKey
+	for product := range products {
+		if _, ok := p.products[product]; !ok {
+			added = append(added, product)
+		}
+	}
+	for product := range before {
+		if !products[product] {
+			p.removeProduct(product)
+		}
+	}
+	p.lock.Unlock()
+	p.flush()
+
+	// Only fetch products that are new.
+	for _, productKey := range added {
+		product, err := p.client.GetProductAccount(ctx, productKey, rpc.CommitmentConfirmed)
+		if err != nil {
+			return err
+		}
+		if err := p.applyProductAccount(ctx, productKey, product.FirstPrice, product.Attrs.KVs()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyProductAccount applies a product account change.
+//
+// The price list is only walked again if its head changed.
+func (p *ProductWatcher) applyProductAccount(ctx context.Context, key solana.PublicKey, firstPrice solana.PublicKey, attrs map[string]string) error {
+	p.lock.Lock()
+	state, ok := p.products[key]
+	p.lock.Unlock()
+
+	var prices map[solana.PublicKey]bool
+	if ok && state.firstPrice.Equals(firstPrice) {
+		prices = state.prices
+	} else {
+		var err error
+		if prices, err = p.walkPrices(ctx, firstPrice); err != nil {
+			return err
+		}
+	}
+
+	p.lock.Lock()
+	p.applyProduct(key, firstPrice, attrs, prices)
+	p.lock.Unlock()
+	p.flush()
+	return nil
+}
+
+// walkPrices follows the price account list of a product, using streamed price accounts where possible.
+func (p *ProductWatcher) walkPrices(ctx context.Context, firstPrice solana.PublicKey) (map[solana.PublicKey]bool, error) {
+	keys := make(map[solana.PublicKey]bool)
+	for key := firstPrice; !key.IsZero() && !keys[key]; {
+		entry, err := p.prices.GetPrice(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		keys[key] = true
+		key = entry.Next
+	}
+	return keys, nil
+}
+
+// sync takes a full snapshot of the catalog and compares it against the known state.
+//
+// The first snapshot does not emit any events.
+func (p *ProductWatcher) sync(ctx context.Context) error {
+	mappings, err := p.getMappings(ctx)
+	if err != nil {
+		return err
+	}
+	products, err := p.client.GetAllProductAccounts(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return err
+	}
+	firstPrices := make([]solana.PublicKey, 0, len(products))
+	for _, product := range products {
+		if !product.FirstPrice.IsZero() {
+			firstPrices = append(firstPrices, product.FirstPrice)
+		}
+	}
+	prices, err := p.client.GetPriceAccountsRecursive(ctx, rpc.CommitmentConfirmed, firstPrices...)
+	if err != nil {
+		return err
+	}
+	priceKeys := make(map[solana.PublicKey]map[solana.PublicKey]bool, len(products))
+	for _, product := range products {
+		priceKeys[product.Pubkey] = make(map[solana.PublicKey]bool)
+	}
+	for _, price := range prices {
+		if keys, ok := priceKeys[price.Product]; ok {
+			keys[price.Pubkey] = true
+		}
+	}
+
+	p.lock.Lock()
+	p.mappings = mappings
+	for key := range p.products {
+		if _, ok := priceKeys[key]; !ok {
+			p.removeProduct(key)
+		}
+	}
+	for _, product := range products {
+		p.applyProduct(product.Pubkey, product.FirstPrice, product.Attrs.KVs(), priceKeys[product.Pubkey])
+	}
+	p.initialized = true
+	catalog := p.catalog()
+	p.lock.Unlock()
+	p.flush()
+
+	for _, callback := range p.onSync {
+		callback(catalog)
+	}
+	return nil
+}
+
+// OnSync registers a callback that gets invoked with the full catalog after each snapshot.
+//
+// Snapshots are taken on every (re)connect, changes in between are reported to Subscribe.
+// Must be called before Run.
+func (p *ProductWatcher) OnSync(callback func(map[solana.PublicKey]ProductInfo)) {
+	p.onSync = append(p.onSync, callback)
+}
+
+// catalog copies the current catalog.
+//
+// Must be called with lock held.
+func (p *ProductWatcher) catalog() map[solana.PublicKey]ProductInfo {
+	catalog := make(map[solana.PublicKey]ProductInfo, len(p.products))
+	for key, state := range p.products {
+		info := ProductInfo{Attrs: state.attrs}
+		for price := range state.prices {
+			info.Prices = append(info.Prices, price)
+		}
+		catalog[key] = info
+	}
+	return catalog
+}
+
+// getMappings fetches the product keys of all mapping accounts.
+func (p *ProductWatcher) getMappings(ctx context.Context) (map[solana.PublicKey]map[solana.PublicKey]bool, error) {
+	mappings := make(map[solana.PublicKey]map[solana.PublicKey]bool)
+	for key := p.client.Env.Mapping; !key.IsZero(); {
+		if _, ok := mappings[key]; ok {
+			return nil, errors.New("mapping account list loops")
+		}
+		res, err := p.client.RPC.GetAccountInfoWithOpts(ctx, key, &rpc.GetAccountInfoOpts{
+			Encoding:   solana.EncodingBase64,
+			Commitment: rpc.CommitmentConfirmed,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var mapping pyth.MappingAccount
+		if err := mapping.UnmarshalBinary(res.Value.Data.GetBinary()); err != nil {
+			return nil, err
+		}
+		mappings[key] = mappingProducts(&mapping)
+		key = mapping.Next
+	}
+	return mappings, nil
+}
+
+func mappingProducts(mapping *pyth.MappingAccount) map[solana.PublicKey]bool {
+	products := make(map[solana.PublicKey]bool)
+	for i := 0; i < int(mapping.Num) && i < len(mapping.Products); i++ {
+		products[mapping.Products[i]] = true
+	}
+	return products
+}
+
+// applyProduct updates the state of a product and queues events for changes.
+//
+// Must be called with lock held.
+func (p *ProductWatcher) applyProduct(key solana.PublicKey, firstPrice solana.PublicKey, attrs map[string]string, prices map[solana.PublicKey]bool) {
+	state, known := p.products[key]
+	p.products[key] = &productState{attrs: attrs, firstPrice: firstPrice, prices: prices}
+	if !p.initialized {
+		return
+	}
+
+	if !known {
+		p.publish(ProductEvent{Type: ProductAdded, Product: key, AttrDict: attrs})
+		state = &productState{}
+	} else if changed := changedKeys(state.attrs, attrs); len(changed) > 0 {
+		p.publish(ProductEvent{Type: ProductChanged, Product: key, AttrDict: attrs, ChangedKeys: changed})
+	}
+	for price := range prices {
+		if !state.prices[price] {
+			price := price
+			p.publish(ProductEvent{Type: PriceAdded, Product: key, Price: &price})
+		}
+	}
+	for price := range state.prices {
+		if !prices[price] {
+			price := price
+			p.publish(ProductEvent{Type: PriceRemoved, Product: key, Price: &price})
+		}
+	}
+}
+
+// removeProduct forgets a product and queues events.
+//
+// Must be called with lock held.
+func (p *ProductWatcher) removeProduct(key solana.PublicKey) {
+	state, ok := p.products[key]
+	if !ok {
+		return
+	}
+	delete(p.products, key)
+	for price := range state.prices {
+		price := price
+		p.publish(ProductEvent{Type: PriceRemoved, Product: key, Price: &price})
+	}
+	p.publish(ProductEvent{Type: ProductRemoved, Product: key})
+}
+
+// publish queues an event to be published by the next flush.
+//
+// Must be called with lock held.
+func (p *ProductWatcher) publish(event ProductEvent) {
+	p.pending = append(p.pending, event)
+}
+
+// flush publishes queued events to subscribers in order.
+//
+// Must be called without lock held, so callbacks may call back into the watcher.
+func (p *ProductWatcher) flush() {
+	p.emitLock.Lock()
+	defer p.emitLock.Unlock()
+
+	p.lock.Lock()
+	events := p.pending
+	p.pending = nil
+	p.lock.Unlock()
+	if len(events) == 0 {
+		return
+	}
+
+	p.subsLock.Lock()
+	callbacks := make([]func(ProductEvent), 0, len(p.subs))
+	for _, callback := range p.subs {
+		callbacks = append(callbacks, callback)
+	}
+	p.subsLock.Unlock()
+
+	for _, event := range events {
+		p.Log.Info("Catalog changed",
+			zap.String("type", event.Type),
+			zap.Stringer("product", event.Product))
+		for _, callback := range callbacks {
+			callback(event)
+		}
+	}
+}
+
+// Subscribe registers a callback function for catalog changes. The returned cancel func unsubscribes.
+//
+// The callback must not block.
+func (p *ProductWatcher) Subscribe(callback func(ProductEvent)) context.CancelFunc {
+	p.subsLock.Lock()
+	defer p.subsLock.Unlock()
+	p.subNonce++
+	id := p.subNonce
+	p.subs[id] = callback
+	return func() {
+		p.subsLock.Lock()
+		defer p.subsLock.Unlock()
+		delete(p.subs, id)
+	}
+}
+
+func changedKeys(before, after map[string]string) []string {
+	var keys []string
+	for k, v := range after {
+		if old, ok := before[k]; !ok || old != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// accountTypeFilter matches Pyth accounts of the given type.
+func accountTypeFilter(accountType uint32) rpc.RPCFilter {
+	var typeBytes [4]byte
+	binary.LittleEndian.PutUint32(typeBytes[:], accountType)
+	return rpc.RPCFilter{
+		Memcmp: &rpc.RPCFilterMemcmp{
+			Offset: 8, // magic (u32), version (u32), type (u32)
+			Bytes:  typeBytes[:],
+		},
+	}
+}
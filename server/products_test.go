@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.blockdaemon.com/pyth"
+)
+
+func TestProductWatcher_Incremental(t *testing.T) {
+	ctx := context.Background()
+	prices := NewPriceCache(nil)
+	watcher := NewProductWatcher(nil, prices)
+	var events []ProductEvent
+	watcher.Subscribe(func(event ProductEvent) {
+		events = append(events, event)
+	})
+
+	mapping := solana.NewWallet().PublicKey()
+	product := solana.NewWallet().PublicKey()
+	price1, price2 := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	// Initial snapshot.
+	watcher.applyProduct(product, price1, map[string]string{"symbol": "FOO/USD"}, map[solana.PublicKey]bool{price1: true})
+	watcher.mappings[mapping] = map[solana.PublicKey]bool{product: true}
+	watcher.initialized = true
+	require.Empty(t, events)
+
+	// Attribute change without touching the price list.
+	require.NoError(t, watcher.applyProductAccount(ctx, product, price1, map[string]string{"symbol": "FOO/USD", "asset_type": "FX"}))
+	require.Len(t, events, 1)
+	assert.Equal(t, ProductChanged, events[0].Type)
+	assert.Equal(t, []string{"asset_type"}, events[0].ChangedKeys)
+	events = nil
+
+	// New price account at the head of the list, already streamed.
+	prices.store(pyth.PriceAccountEntry{
+		Pubkey:       price2,
+		PriceAccount: &pyth.PriceAccount{Product: product, Next: price1},
+	})
+	prices.store(pyth.PriceAccountEntry{
+		Pubkey:       price1,
+		PriceAccount: &pyth.PriceAccount{Product: product},
+	})
+	require.NoError(t, watcher.applyProductAccount(ctx, product, price2, map[string]string{"symbol": "FOO/USD", "asset_type": "FX"}))
+	require.Len(t, events, 1)
+	assert.Equal(t, PriceAdded, events[0].Type)
+	assert.Equal(t, price2, *events[0].Price)
+	events = nil
+
+	// Product dropped from the mapping account.
+	require.NoError(t, watcher.applyMapping(ctx, mapping, map[solana.PublicKey]bool{}))
+	require.Len(t, events, 3)
+	assert.ElementsMatch(t, []solana.PublicKey{price1, price2}, []solana.PublicKey{*events[0].Price, *events[1].Price})
+	assert.Equal(t, ProductEvent{Type: ProductRemoved, Product: product}, events[2])
+}
+
+func TestProductWatcher_PublishUnlocked(t *testing.T) {
+	ctx := context.Background()
+	watcher := NewProductWatcher(nil, NewPriceCache(nil))
+	watcher.initialized = true
+	product := solana.NewWallet().PublicKey()
+
+	// Subscribers may call back into the watcher and see the applied change.
+	var catalogs []map[solana.PublicKey]ProductInfo
+	watcher.Subscribe(func(event ProductEvent) {
+		watcher.lock.Lock()
+		catalogs = append(catalogs, watcher.catalog())
+		watcher.lock.Unlock()
+		watcher.Subscribe(func(ProductEvent) {})()
+	})
+
+	require.NoError(t, watcher.applyProductAccount(ctx, product, solana.PublicKey{}, map[string]string{"symbol": "FOO/USD"}))
+	require.Len(t, catalogs, 1)
+	assert.Contains(t, catalogs[0], product)
+	assert.Empty(t, watcher.pending)
+}
@@ -1,6 +1,12 @@
 package server
 
-import "go.blockdaemon.com/pyth"
+import (
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"go.blockdaemon.com/pyth"
+	"go.blockdaemon.com/pythian/schedule"
+)
 
 type productAccount struct {
 	Account  string            `json:"account"`
@@ -45,17 +51,132 @@ type publisherAccount struct {
 	Slot    uint64 `json:"slot"`
 }
 
+type updatePriceResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type aggregatePreview struct {
+	Status       string `json:"status"`
+	Price        int64  `json:"price"`
+	Conf         uint64 `json:"conf"`
+	Exponent     int32  `json:"exponent"`
+	NumQuotes    int    `json:"num_quotes"`
+	Slot         uint64 `json:"slot"`
+	CurrentPrice int64  `json:"current_price"`
+	CurrentConf  uint64 `json:"current_conf"`
+}
+
+type adminStatus struct {
+	Buffer      schedule.BufferStatus    `json:"buffer"`
+	Scheduler   schedule.SchedulerStatus `json:"scheduler"`
+	Slot        uint64                   `json:"slot"`
+	Blockhash   solana.Hash              `json:"blockhash"`
+	HashBlocks  uint64                   `json:"blockhash_remaining_blocks"`
+	LogLevel    string                   `json:"log_level"`
+	Quarantined []QuarantinedUpdate      `json:"quarantined,omitempty"`
+}
+
 type subscriptionUpdate struct {
 	Result       interface{} `json:"result,omitempty"`
 	Subscription uint64      `json:"subscription"`
 }
 
+type txStatusUpdate struct {
+	Signature solana.Signature `json:"signature"`
+	Status    string           `json:"status"`
+	Slot      uint64           `json:"slot"`
+	Error     string           `json:"error,omitempty"`
+}
+
 type priceUpdate struct {
 	Price     int64  `json:"price"`
 	Conf      uint64 `json:"conf"`
 	Status    string `json:"status"`
 	ValidSlot uint64 `json:"valid_slot"`
 	PubSlot   uint64 `json:"pub_slot"`
+
+	// Opt-in fields.
+	*priceUpdateEMA
+	*priceUpdatePrev
+	PublisherAccounts []publisherAccount `json:"publisher_accounts,omitempty"`
+}
+
+type priceUpdateEMA struct {
+	EmaPrice      int64 `json:"ema_price"`
+	EmaConfidence int64 `json:"ema_confidence"`
+}
+
+type priceUpdatePrev struct {
+	PrevSlot  uint64 `json:"prev_slot"`
+	PrevPrice int64  `json:"prev_price"`
+	PrevConf  int64  `json:"prev_conf"`
+}
+
+// Opt-in fields of price notifications.
+const (
+	priceFieldEMA        = "ema"
+	priceFieldPrev       = "prev"
+	priceFieldComponents = "components"
+)
+
+type priceUpdateFields struct {
+	ema, prev, components bool
+}
+
+func parsePriceUpdateFields(fields []string) (f priceUpdateFields, err error) {
+	for _, field := range fields {
+		switch field {
+		case priceFieldEMA:
+			f.ema = true
+		case priceFieldPrev:
+			f.prev = true
+		case priceFieldComponents:
+			f.components = true
+		default:
+			return f, fmt.Errorf("unknown field: %s", field)
+		}
+	}
+	return f, nil
+}
+
+func newPriceUpdate(acc *pyth.PriceAccountEntry, info *pyth.PriceInfo, fields priceUpdateFields) *priceUpdate {
+	update := &priceUpdate{
+		Price:     info.Price,
+		Conf:      info.Conf,
+		Status:    statusToString(info.Status),
+		ValidSlot: acc.ValidSlot,
+		PubSlot:   info.PubSlot,
+	}
+	if fields.ema {
+		update.priceUpdateEMA = &priceUpdateEMA{
+			EmaPrice:      acc.Twap.Val,
+			EmaConfidence: acc.Twac.Val,
+		}
+	}
+	if fields.prev {
+		update.priceUpdatePrev = &priceUpdatePrev{
+			PrevSlot:  acc.PrevSlot,
+			PrevPrice: acc.PrevPrice,
+			PrevConf:  int64(acc.PrevConf),
+		}
+	}
+	if fields.components {
+		update.PublisherAccounts = componentsToJSON(acc)
+	}
+	return update
+}
+
+type publisherPriceUpdate struct {
+	Publisher solana.PublicKey `json:"publisher"`
+	Price     int64            `json:"price"`
+	Conf      uint64           `json:"conf"`
+	Status    string           `json:"status"`
+	PubSlot   uint64           `json:"pub_slot"`
+	PrevPrice int64            `json:"prev_price"`
+	PrevConf  uint64           `json:"prev_conf"`
+	AggPrice  int64            `json:"agg_price"`
+	AggConf   uint64           `json:"agg_conf"`
 }
 
 func productToJSON(product pyth.ProductAccountEntry, prices []pyth.PriceAccountEntry) productAccount {
@@ -106,6 +227,11 @@ func priceToDetailJSON(price pyth.PriceAccountEntry) priceAccountDetail {
 		PrevPrice:     price.PrevPrice,
 		PrevConf:      int64(price.PrevConf),
 	}
+	acc.PublisherAccounts = componentsToJSON(&price)
+	return acc
+}
+
+func componentsToJSON(price *pyth.PriceAccountEntry) []publisherAccount {
 	publishers := make([]publisherAccount, 0, len(price.Components))
 	for _, comp := range price.Components {
 		if comp.Publisher.IsZero() {
@@ -119,8 +245,7 @@ func priceToDetailJSON(price pyth.PriceAccountEntry) priceAccountDetail {
 			Slot:    comp.Latest.PubSlot,
 		})
 	}
-	acc.PublisherAccounts = publishers
-	return acc
+	return publishers
 }
 
 func priceTypeToString(priceType uint32) string {
@@ -2,40 +2,81 @@
 package signer
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
 )
 
 // Signer signs Solana transactions carrying Pyth price updates.
+//
+// It may hold multiple publisher keys.
 type Signer struct {
-	privateKey  solana.PrivateKey
-	publicKey   solana.PublicKey
+	privateKeys map[solana.PublicKey]solana.PrivateKey
+	publicKeys  []solana.PublicKey
 	pythProgram solana.PublicKey
+	programs    map[solana.PublicKey]bool // additionally allowed programs
+	nonces      bool                      // allow advancing durable nonces
 }
 
-// NewSigner loads the unencrypted private key from the provided file.
-func NewSigner(privateKeyPath string, pythProgram solana.PublicKey) (*Signer, error) {
-	pk, err := solana.PrivateKeyFromSolanaKeygenFile(privateKeyPath)
-	if err != nil {
-		return nil, err
+// NewSigner loads the unencrypted private keys from the provided files.
+func NewSigner(privateKeyPaths []string, pythProgram solana.PublicKey) (*Signer, error) {
+	if len(privateKeyPaths) == 0 {
+		return nil, fmt.Errorf("no private keys")
 	}
-	return &Signer{
-		privateKey:  pk,
-		publicKey:   pk.PublicKey(),
+	s := &Signer{
+		privateKeys: make(map[solana.PublicKey]solana.PrivateKey, len(privateKeyPaths)),
 		pythProgram: pythProgram,
-	}, nil
+		programs:    make(map[solana.PublicKey]bool),
+	}
+	for _, path := range privateKeyPaths {
+		pk, err := solana.PrivateKeyFromSolanaKeygenFile(path)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		pubkey := pk.PublicKey()
+		if _, ok := s.privateKeys[pubkey]; ok {
+			s.Close()
+			return nil, fmt.Errorf("duplicate private key %s", pubkey)
+		}
+		s.privateKeys[pubkey] = pk
+		s.publicKeys = append(s.publicKeys, pubkey)
+	}
+	return s, nil
 }
 
-// Pubkey returns the public key of the wallet being managed.
+// Pubkey returns the public key of the first wallet being managed.
 func (s *Signer) Pubkey() solana.PublicKey {
-	return s.publicKey
+	return s.publicKeys[0]
+}
+
+// Pubkeys returns the public keys of all wallets being managed, in load order.
+func (s *Signer) Pubkeys() []solana.PublicKey {
+	return append([]solana.PublicKey(nil), s.publicKeys...)
+}
+
+// AllowPrograms permits instructions of the given programs alongside Pyth instructions,
+// e.g. the compute budget program.
+func (s *Signer) AllowPrograms(programs ...solana.PublicKey) {
+	for _, program := range programs {
+		s.programs[program] = true
+	}
+}
+
+// AllowNonceAdvance permits advancing durable nonce accounts.
+// Other system program instructions stay forbidden.
+func (s *Signer) AllowNonceAdvance() {
+	s.nonces = true
 }
 
 // Close should be called when a signer is not used anymore.
 func (s *Signer) Close() {
-	for i := range s.privateKey {
-		s.privateKey[i] = 0
+	for _, pk := range s.privateKeys {
+		for i := range pk {
+			pk[i] = 0
+		}
 	}
 }
 
@@ -61,7 +102,10 @@ func (s *Signer) SignPriceUpdate(tx *solana.Transaction) error {
 		*/
 		// Reject if requested sig for unknown program instruction.
 		requestedProgram := tx.Message.AccountKeys[op.ProgramIDIndex]
-		if !requestedProgram.Equals(s.pythProgram) {
+		if s.nonces && requestedProgram.Equals(solana.SystemProgramID) && isAdvanceNonce(op.Data) {
+			continue
+		}
+		if !requestedProgram.Equals(s.pythProgram) && !s.programs[requestedProgram] {
 			return fmt.Errorf("refusing to sign for program %s", requestedProgram.String())
 		}
 		// TODO(richard): Restrict to price updates.
@@ -69,11 +113,17 @@ func (s *Signer) SignPriceUpdate(tx *solana.Transaction) error {
 
 	// Actually sign.
 	_, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
-		if !key.Equals(s.publicKey) {
+		pk, ok := s.privateKeys[key]
+		if !ok {
 			return nil
 		}
-		return &s.privateKey
+		return &pk
 	})
 
 	return err
 }
+
+// isAdvanceNonce returns whether system program instruction data advances a nonce account.
+func isAdvanceNonce(data []byte) bool {
+	return len(data) == 4 && binary.LittleEndian.Uint32(data) == system.Instruction_AdvanceNonceAccount
+}